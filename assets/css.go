@@ -0,0 +1,44 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package assets
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// cssUrlPattern matches CSS url(...) references, capturing the
+// optional surrounding quote characters separately so they can be
+// preserved when rewriting.
+var cssUrlPattern = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)['"]?\s*\)`)
+
+// rewriteCSSAssets downloads every url(...) reference in a stylesheet
+// fetched from cssUrl and rewrites them to the downloaded asset's path
+// relative to the stylesheet itself.
+func (r *Rewriter) rewriteCSSAssets(ctx context.Context, httpClient *http.Client, userAgent, cssUrl string, body []byte) ([]byte, error) {
+	var rewriteErr error
+	rewritten := cssUrlPattern.ReplaceAllStringFunc(string(body), func(match string) string {
+		if rewriteErr != nil {
+			return match
+		}
+		groups := cssUrlPattern.FindStringSubmatch(match)
+		raw := strings.TrimSpace(groups[2])
+		if raw == "" || strings.HasPrefix(raw, "data:") {
+			return match
+		}
+		assetUrl := resolve(cssUrl, raw)
+		localPath, err := r.fetchAndSave(ctx, httpClient, userAgent, cssUrl, assetUrl)
+		if err != nil {
+			rewriteErr = err
+			return match
+		}
+		return "url(" + groups[1] + localPath + groups[1] + ")"
+	})
+	if rewriteErr != nil {
+		return nil, rewriteErr
+	}
+	return []byte(rewritten), nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package assets
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ensureExtension returns rawUrl unchanged if its path already ends in
+// a file extension. Otherwise it sniffs one from contentType (falling
+// back to sniffing body itself) and appends it, so the asset lands on
+// disk with an extension a file:// browser can use to guess its type.
+func ensureExtension(rawUrl string, body []byte, contentType string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing url %v", rawUrl)
+	}
+	if path.Ext(u.Path) != "" {
+		return rawUrl, nil
+	}
+	ext := extensionFor(contentType, body)
+	if ext == "" {
+		return rawUrl, nil
+	}
+	u.Path += ext
+	return u.String(), nil
+}
+
+func extensionFor(contentType string, body []byte) string {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	return exts[0]
+}
+
+// relativePath returns the path of asset, identified by its on-disk
+// mirrored URL assetUrl, relative to the directory referrerUrl is
+// mirrored to. It mirrors storage.FileStorage's own layout rules
+// without depending on it, since both URLs are mirrored the same way
+// regardless of the destination directory.
+func relativePath(referrerUrl, assetUrl string) (string, error) {
+	referrerPath, err := diskPath(referrerUrl)
+	if err != nil {
+		return "", err
+	}
+	assetPath, err := diskPath(assetUrl)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(path.Dir(referrerPath), assetPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "computing path from %v to %v", referrerUrl, assetUrl)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func diskPath(rawUrl string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing url %v", rawUrl)
+	}
+	if strings.HasSuffix(u.Path, "/") {
+		return path.Join(u.Host, u.Path, "index.html"), nil
+	}
+	return path.Join(u.Host, u.Path), nil
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// Package assets discovers, downloads, and rewrites the static
+// resources referenced by a crawled page (images, stylesheets,
+// scripts, and CSS url(...) references) so the resulting mirror is
+// browsable offline via file://, the way `wget --mirror
+// --convert-links` behaves.
+package assets
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+	"github.com/tcmsoftware/mirroring-web-crawler/storage"
+)
+
+// defaultConcurrency is the asset download concurrency used when
+// NewRewriter is given a non-positive value.
+const defaultConcurrency = 5
+
+// Fetcher downloads url and returns its body and Content-Type header.
+// For ease of unit testing, so we can inject everything we need to.
+type Fetcher func(ctx context.Context, httpClient *http.Client, userAgent, url string) ([]byte, string, error)
+
+func defaultFetcher(ctx context.Context, httpClient *http.Client, userAgent, rawUrl string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// Rewriter downloads the assets referenced by crawled pages to st and
+// rewrites the referencing attributes to the asset's relative on-disk
+// path. It keeps its own visited set, separate from the page frontier,
+// and bounds how many assets are downloaded in parallel.
+type Rewriter struct {
+	storage storage.Storage
+	fetch   Fetcher
+	tokens  chan struct{}
+
+	mu    sync.Mutex
+	saved map[string]string // asset url -> effective url (with extension fallback applied) used to save it
+}
+
+// NewRewriter creates a Rewriter that saves assets to st, downloading
+// at most concurrency of them at a time.
+func NewRewriter(st storage.Storage, concurrency int) *Rewriter {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	return &Rewriter{
+		storage: st,
+		fetch:   defaultFetcher,
+		tokens:  make(chan struct{}, concurrency),
+		saved:   make(map[string]string),
+	}
+}
+
+// Process discovers every img/script/stylesheet/srcset reference in
+// doc, downloads the assets they point to (and, for stylesheets, the
+// url(...) references inside them), and rewrites those attributes in
+// place to relative on-disk paths. doc is assumed to have been loaded
+// from pageUrl.
+func (r *Rewriter) Process(ctx context.Context, httpClient *http.Client, userAgent string, doc *goquery.Document, pageUrl string) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	recordErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	rewriteAttr := func(s *goquery.Selection, attr string) {
+		raw, exists := s.Attr(attr)
+		if !exists || raw == "" || strings.HasPrefix(raw, "data:") {
+			return
+		}
+		assetUrl := resolve(pageUrl, raw)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			localPath, err := r.fetchAndSave(ctx, httpClient, userAgent, pageUrl, assetUrl)
+			if err != nil {
+				recordErr(errors.Wrapf(err, "rewriting %s on %s", attr, pageUrl))
+				return
+			}
+			s.SetAttr(attr, localPath)
+		}()
+	}
+
+	doc.Find("img[src]").Each(func(_ int, s *goquery.Selection) { rewriteAttr(s, "src") })
+	doc.Find("script[src]").Each(func(_ int, s *goquery.Selection) { rewriteAttr(s, "src") })
+	doc.Find(`link[rel="stylesheet"][href]`).Each(func(_ int, s *goquery.Selection) { rewriteAttr(s, "href") })
+	doc.Find("source[srcset]").Each(func(_ int, s *goquery.Selection) {
+		raw, exists := s.Attr("srcset")
+		if !exists || raw == "" {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rewritten, err := r.rewriteSrcset(ctx, httpClient, userAgent, pageUrl, raw)
+			if err != nil {
+				recordErr(errors.Wrapf(err, "rewriting srcset on %s", pageUrl))
+				return
+			}
+			s.SetAttr("srcset", rewritten)
+		}()
+	})
+
+	wg.Wait()
+	return firstErr
+}
+
+// fetchAndSave downloads assetUrl the first time it is seen, saving it
+// to storage under its effective URL (its path with a MIME-sniffed
+// extension appended when it has none), and returns that asset's path
+// relative to referrerUrl.
+func (r *Rewriter) fetchAndSave(ctx context.Context, httpClient *http.Client, userAgent, referrerUrl, assetUrl string) (string, error) {
+	r.mu.Lock()
+	effectiveUrl, ok := r.saved[assetUrl]
+	r.mu.Unlock()
+	if !ok {
+		var err error
+		effectiveUrl, err = r.download(ctx, httpClient, userAgent, assetUrl)
+		if err != nil {
+			return "", err
+		}
+		r.mu.Lock()
+		r.saved[assetUrl] = effectiveUrl
+		r.mu.Unlock()
+	}
+	return relativePath(referrerUrl, effectiveUrl)
+}
+
+func (r *Rewriter) download(ctx context.Context, httpClient *http.Client, userAgent, assetUrl string) (string, error) {
+	select {
+	case r.tokens <- struct{}{}:
+		defer func() { <-r.tokens }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	body, contentType, err := r.fetch(ctx, httpClient, userAgent, assetUrl)
+	if err != nil {
+		return "", errors.Wrapf(err, "fetching asset %v", assetUrl)
+	}
+	if isCSS(assetUrl, contentType) {
+		body, err = r.rewriteCSSAssets(ctx, httpClient, userAgent, assetUrl, body)
+		if err != nil {
+			return "", err
+		}
+	}
+	effectiveUrl, err := ensureExtension(assetUrl, body, contentType)
+	if err != nil {
+		return "", err
+	}
+	if err := r.storage.Put(effectiveUrl, http.StatusOK, nil, body); err != nil {
+		return "", errors.Wrapf(err, "saving asset %v", assetUrl)
+	}
+	return effectiveUrl, nil
+}
+
+func (r *Rewriter) rewriteSrcset(ctx context.Context, httpClient *http.Client, userAgent, pageUrl, raw string) (string, error) {
+	candidates := strings.Split(raw, ",")
+	for i, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		parts := strings.Fields(candidate)
+		if len(parts) == 0 || strings.HasPrefix(parts[0], "data:") {
+			candidates[i] = candidate
+			continue
+		}
+		assetUrl := resolve(pageUrl, parts[0])
+		localPath, err := r.fetchAndSave(ctx, httpClient, userAgent, pageUrl, assetUrl)
+		if err != nil {
+			return "", err
+		}
+		parts[0] = localPath
+		candidates[i] = strings.Join(parts, " ")
+	}
+	return strings.Join(candidates, ", "), nil
+}
+
+func isCSS(assetUrl, contentType string) bool {
+	if strings.Contains(contentType, "text/css") {
+		return true
+	}
+	u, err := url.Parse(assetUrl)
+	return err == nil && strings.HasSuffix(u.Path, ".css")
+}
+
+func resolve(base, ref string) string {
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refUrl, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseUrl.ResolveReference(refUrl).String()
+}
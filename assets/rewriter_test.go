@@ -0,0 +1,225 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package assets
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage is an in-memory storage.Storage used to assert what the
+// Rewriter persisted, without touching the filesystem. It is safe for
+// concurrent use since Process saves assets in parallel.
+type fakeStorage struct {
+	mu    sync.Mutex
+	saved map[string][]byte
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{saved: make(map[string][]byte)}
+}
+
+func (s *fakeStorage) Exists(url string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.saved[url]
+	return ok
+}
+
+func (s *fakeStorage) Put(url string, statusCode int, headers http.Header, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved[url] = body
+	return nil
+}
+
+func (s *fakeStorage) Close() error {
+	return nil
+}
+
+func parseDoc(t *testing.T, html, docUrl string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	require.NoError(t, err)
+	return doc
+}
+
+func Test_Rewriter_Process(t *testing.T) {
+	const pageUrl = "http://example.com/page.html"
+	html := `
+	<html>
+		<head><link rel="stylesheet" href="/style.css"></head>
+		<body>
+			<img src="/logo">
+			<script src="/app.js"></script>
+		</body>
+	</html>
+	`
+	var fetchedMu sync.Mutex
+	fetched := map[string]string{}
+	st := newFakeStorage()
+	r := NewRewriter(st, 4)
+	r.fetch = func(ctx context.Context, httpClient *http.Client, userAgent, url string) ([]byte, string, error) {
+		fetchedMu.Lock()
+		fetched[url] = userAgent
+		fetchedMu.Unlock()
+		switch url {
+		case "http://example.com/style.css":
+			return []byte(`body { background: url(/bg.png); }`), "text/css", nil
+		case "http://example.com/logo":
+			return []byte("\x89PNG\r\n\x1a\n"), "", nil
+		case "http://example.com/app.js":
+			return []byte("console.log(1)"), "application/javascript", nil
+		case "http://example.com/bg.png":
+			return []byte("\x89PNG\r\n\x1a\n"), "image/png", nil
+		}
+		return nil, "", nil
+	}
+
+	doc := parseDoc(t, html, pageUrl)
+	err := r.Process(context.Background(), new(http.Client), "someagent", doc, pageUrl)
+	require.NoError(t, err)
+
+	href, _ := doc.Find("link").Attr("href")
+	require.Equal(t, "style.css", href)
+	src, _ := doc.Find("img").Attr("src")
+	require.Equal(t, "logo.png", src)
+	scriptSrc, _ := doc.Find("script").Attr("src")
+	require.Equal(t, "app.js", scriptSrc)
+
+	require.Equal(t, "someagent", fetched["http://example.com/style.css"])
+	require.Contains(t, string(st.saved["http://example.com/style.css"]), "url(bg.png)")
+	require.Contains(t, st.saved, "http://example.com/logo.png")
+	require.Contains(t, st.saved, "http://example.com/bg.png")
+}
+
+func Test_Rewriter_DedupesRepeatedAssets(t *testing.T) {
+	const pageUrl = "http://example.com/page.html"
+	html := `
+	<html><body>
+		<img src="/shared.png">
+		<img src="/shared.png">
+	</body></html>
+	`
+	var fetchMu sync.Mutex
+	var fetchCount int
+	st := newFakeStorage()
+	r := NewRewriter(st, 4)
+	r.fetch = func(ctx context.Context, httpClient *http.Client, userAgent, url string) ([]byte, string, error) {
+		fetchMu.Lock()
+		fetchCount++
+		fetchMu.Unlock()
+		return []byte("\x89PNG\r\n\x1a\n"), "image/png", nil
+	}
+
+	doc := parseDoc(t, html, pageUrl)
+	err := r.Process(context.Background(), new(http.Client), "someagent", doc, pageUrl)
+	require.NoError(t, err)
+	require.Equal(t, 1, fetchCount)
+}
+
+func Test_Rewriter_Srcset(t *testing.T) {
+	const pageUrl = "http://example.com/page.html"
+	html := `<html><body><picture><source srcset="/small.jpg 480w, /large.jpg 800w"></picture></body></html>`
+	st := newFakeStorage()
+	r := NewRewriter(st, 4)
+	r.fetch = func(ctx context.Context, httpClient *http.Client, userAgent, url string) ([]byte, string, error) {
+		return []byte("\xff\xd8\xff"), "image/jpeg", nil
+	}
+	doc := parseDoc(t, html, pageUrl)
+	err := r.Process(context.Background(), new(http.Client), "someagent", doc, pageUrl)
+	require.NoError(t, err)
+	srcset, _ := doc.Find("source").Attr("srcset")
+	require.Equal(t, "small.jpg 480w, large.jpg 800w", srcset)
+}
+
+func Test_Rewriter_DownloadError(t *testing.T) {
+	const pageUrl = "http://example.com/page.html"
+	html := `<html><body><img src="/broken.png"></body></html>`
+	st := newFakeStorage()
+	r := NewRewriter(st, 4)
+	r.fetch = func(ctx context.Context, httpClient *http.Client, userAgent, url string) ([]byte, string, error) {
+		return nil, "", io.ErrUnexpectedEOF
+	}
+	doc := parseDoc(t, html, pageUrl)
+	err := r.Process(context.Background(), new(http.Client), "someagent", doc, pageUrl)
+	require.Error(t, err)
+}
+
+func Test_ensureExtension(t *testing.T) {
+	testCases := []struct {
+		name        string
+		url         string
+		contentType string
+		body        []byte
+		expected    string
+	}{
+		{
+			name:     "already has extension",
+			url:      "http://example.com/logo.png",
+			expected: "http://example.com/logo.png",
+		},
+		{
+			name:        "sniffed from content-type",
+			url:         "http://example.com/logo",
+			contentType: "image/png",
+			expected:    "http://example.com/logo.png",
+		},
+		{
+			name:     "sniffed from body when content-type is empty",
+			url:      "http://example.com/logo",
+			body:     []byte("\x89PNG\r\n\x1a\n"),
+			expected: "http://example.com/logo.png",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ensureExtension(tc.url, tc.body, tc.contentType)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func Test_relativePath(t *testing.T) {
+	testCases := []struct {
+		name        string
+		referrerUrl string
+		assetUrl    string
+		expected    string
+	}{
+		{
+			name:        "same directory",
+			referrerUrl: "http://example.com/page.html",
+			assetUrl:    "http://example.com/style.css",
+			expected:    "style.css",
+		},
+		{
+			name:        "nested page to root asset",
+			referrerUrl: "http://example.com/blog/post.html",
+			assetUrl:    "http://example.com/style.css",
+			expected:    "../style.css",
+		},
+		{
+			name:        "root page to nested asset",
+			referrerUrl: "http://example.com/page.html",
+			assetUrl:    "http://example.com/assets/logo.png",
+			expected:    "assets/logo.png",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := relativePath(tc.referrerUrl, tc.assetUrl)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// Package checkpoint persists per-URL crawl progress to disk so a crawl
+// interrupted mid-run can resume without re-downloading pages it already
+// fetched successfully.
+package checkpoint
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tcmsoftware/mirroring-web-crawler/frontier"
+)
+
+// Status records the outcome of the last attempt to fetch a URL.
+type Status int
+
+const (
+	// StatusOK means the URL was fetched and persisted successfully.
+	StatusOK Status = iota
+	// StatusError means the last attempt to fetch the URL failed.
+	StatusError
+)
+
+// Entry is the checkpointed state for a single URL.
+type Entry struct {
+	Status       Status    `json:"status"`
+	ContentHash  string    `json:"content_hash,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// file is the on-disk representation of a Store.
+type file struct {
+	Entries map[string]Entry `json:"entries"`
+	Pending []frontier.Item  `json:"pending,omitempty"`
+}
+
+// Store is a URL -> Entry map backed by a JSON file on disk, plus the
+// list of items that were still queued when the Store was last flushed.
+// It is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]Entry
+	pending []frontier.Item
+}
+
+// Load reads the checkpoint file at path, if it exists, and returns a
+// Store ready to be queried and updated. A missing file is not an error
+// and results in an empty Store.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, entries: make(map[string]Entry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading checkpoint file %v", path)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, errors.Wrapf(err, "parsing checkpoint file %v", path)
+	}
+	s.entries = f.Entries
+	if s.entries == nil {
+		s.entries = make(map[string]Entry)
+	}
+	s.pending = f.Pending
+	return s, nil
+}
+
+// Get returns the checkpointed entry for url, if any.
+func (s *Store) Get(url string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[url]
+	return entry, ok
+}
+
+// Set records or replaces the checkpointed entry for url.
+func (s *Store) Set(url string, entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[url] = entry
+}
+
+// Done reports whether url was already fetched successfully in a
+// previous run.
+func (s *Store) Done(url string) bool {
+	entry, ok := s.Get(url)
+	return ok && entry.Status == StatusOK
+}
+
+// URLs returns every URL currently recorded in the Store.
+func (s *Store) URLs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	urls := make([]string, 0, len(s.entries))
+	for url := range s.entries {
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// Pending returns the items that were still queued, and not yet
+// processed, when the Store was last flushed.
+func (s *Store) Pending() []frontier.Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// SetPending replaces the list of items still queued.
+func (s *Store) SetPending(items []frontier.Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = items
+}
+
+// Flush writes the current state of the Store to its path, replacing
+// any existing file.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(file{Entries: s.entries, Pending: s.pending}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshaling checkpoint")
+	}
+	if err := os.WriteFile(s.path, data, 0o644); err != nil {
+		return errors.Wrapf(err, "writing checkpoint file %v", s.path)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package checkpoint
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Store_LoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "visited.json"))
+	require.NoError(t, err)
+	require.False(t, s.Done("http://example.com/"))
+}
+
+func Test_Store_SetGetFlushLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "visited.json")
+	s, err := Load(path)
+	require.NoError(t, err)
+
+	entry := Entry{
+		Status:       StatusOK,
+		ContentHash:  "abc123",
+		ETag:         `"etag"`,
+		LastModified: "Wed, 21 Oct 2015 07:28:00 GMT",
+		FetchedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+	s.Set("http://example.com/", entry)
+	require.True(t, s.Done("http://example.com/"))
+	require.NoError(t, s.Flush())
+
+	reloaded, err := Load(path)
+	require.NoError(t, err)
+	got, ok := reloaded.Get("http://example.com/")
+	require.True(t, ok)
+	require.Equal(t, entry, got)
+}
+
+func Test_Store_Done(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "visited.json"))
+	require.NoError(t, err)
+	s.Set("http://example.com/error.html", Entry{Status: StatusError})
+	require.False(t, s.Done("http://example.com/error.html"))
+	s.Set("http://example.com/ok.html", Entry{Status: StatusOK})
+	require.True(t, s.Done("http://example.com/ok.html"))
+}
@@ -4,49 +4,291 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tcmsoftware/mirroring-web-crawler/crawler"
+	"github.com/tcmsoftware/mirroring-web-crawler/storage"
 )
 
-func run(log *log.Logger, startUrl, destDir string) error {
+// options bundles the flags controlling how a crawl behaves, as
+// opposed to flags like -format/-output that only affect where its
+// output goes.
+type options struct {
+	userAgent        string
+	minDelay         time.Duration
+	maxDepth         int
+	maxPages         int
+	includeRegexp    string
+	excludeRegexp    string
+	concurrency      int
+	checkpoint       string
+	revalidate       bool
+	rewriteAssets    bool
+	assetConcurrency int
+	sitemap          bool
+	since            time.Time
+	sitemapOnly      bool
+	resume           bool
+	maxWarcFileSize  int64
+	configPath       string
+	sources          string
+	vtApiKey         string
+	rpsPerHost       float64
+	maxBandwidth     int
+	renderJS         bool
+	renderWait       string
+	renderTimeout    time.Duration
+}
+
+// buildSeeders translates sources, a comma-separated subset of
+// "sitemap,wayback,commoncrawl,vt", into the matching crawler.Seeders.
+func buildSeeders(sources string, since time.Time, vtApiKey string) ([]crawler.Seeder, error) {
+	var seeders []crawler.Seeder
+	for _, name := range strings.Split(sources, ",") {
+		switch strings.TrimSpace(name) {
+		case "sitemap":
+			seeders = append(seeders, crawler.SitemapSeeder{Since: since})
+		case "wayback":
+			seeders = append(seeders, crawler.WaybackSeeder{})
+		case "commoncrawl":
+			seeders = append(seeders, crawler.CommonCrawlSeeder{})
+		case "vt":
+			if vtApiKey == "" {
+				return nil, errors.New("-sources includes vt but -vt-api-key is empty")
+			}
+			seeders = append(seeders, crawler.VirusTotalSeeder{APIKey: vtApiKey})
+		default:
+			return nil, errors.Errorf("unknown source %q, must be one of: sitemap, wayback, commoncrawl, vt", name)
+		}
+	}
+	return seeders, nil
+}
+
+func run(log *log.Logger, startUrl, destDir, format, warcFile string, opts options) error {
 	log.Println("main: starting web crawler")
 	defer log.Println("main: completed")
 	const defaultTimeOut = 10 * time.Second
-	if startUrl == "" {
+
+	var cfg *crawler.Config
+	if opts.configPath != "" {
+		loaded, err := crawler.LoadConfig(opts.configPath)
+		if err != nil {
+			return err
+		}
+		if startUrl != "" {
+			loaded.Seeds = []string{startUrl}
+		}
+		if destDir != "" {
+			loaded.DestDir = destDir
+		}
+		cfg = loaded
+		destDir = cfg.DestDir
+	} else if startUrl == "" {
 		return errors.New("missing start url")
-	}
-	if destDir == "" {
+	} else if destDir == "" {
 		return errors.New("missing dest dir")
 	}
-	c := crawler.New(startUrl, destDir, defaultTimeOut, log)
+
+	st, err := newStorage(format, destDir, warcFile, opts.userAgent, opts.maxWarcFileSize)
+	if err != nil {
+		return err
+	}
+	var crawlerOpts []crawler.Option
+	if cfg == nil {
+		crawlerOpts = append(crawlerOpts,
+			crawler.WithUserAgent(opts.userAgent),
+			crawler.WithMinDelay(opts.minDelay),
+			crawler.WithMaxDepth(opts.maxDepth),
+			crawler.WithMaxPages(opts.maxPages),
+			crawler.WithConcurrency(opts.concurrency),
+		)
+		if opts.includeRegexp != "" {
+			re, err := regexp.Compile(opts.includeRegexp)
+			if err != nil {
+				return errors.Wrap(err, "compiling include regexp")
+			}
+			crawlerOpts = append(crawlerOpts, crawler.WithIncludeRegexp(re))
+		}
+		if opts.excludeRegexp != "" {
+			re, err := regexp.Compile(opts.excludeRegexp)
+			if err != nil {
+				return errors.Wrap(err, "compiling exclude regexp")
+			}
+			crawlerOpts = append(crawlerOpts, crawler.WithExcludeRegexp(re))
+		}
+	}
+	crawlerOpts = append(crawlerOpts,
+		crawler.WithStorage(st),
+		crawler.WithRevalidate(opts.revalidate),
+	)
+	if opts.checkpoint != "" {
+		crawlerOpts = append(crawlerOpts,
+			crawler.WithCheckpoint(opts.checkpoint),
+			crawler.WithResume(opts.resume),
+		)
+	}
+	if opts.rewriteAssets {
+		crawlerOpts = append(crawlerOpts,
+			crawler.WithAssetRewriting(true),
+			crawler.WithAssetConcurrency(opts.assetConcurrency),
+		)
+	}
+	if opts.sitemap {
+		crawlerOpts = append(crawlerOpts,
+			crawler.WithSitemap(true),
+			crawler.WithSince(opts.since),
+			crawler.WithSitemapOnly(opts.sitemapOnly),
+		)
+	}
+	if opts.sources != "" {
+		seeders, err := buildSeeders(opts.sources, opts.since, opts.vtApiKey)
+		if err != nil {
+			return err
+		}
+		crawlerOpts = append(crawlerOpts, crawler.WithSeeders(seeders...))
+	}
+	if opts.rpsPerHost > 0 {
+		crawlerOpts = append(crawlerOpts, crawler.WithRPSPerHost(opts.rpsPerHost))
+	}
+	if opts.maxBandwidth > 0 {
+		crawlerOpts = append(crawlerOpts, crawler.WithMaxBandwidth(opts.maxBandwidth))
+	}
+	if opts.renderJS {
+		crawlerOpts = append(crawlerOpts, crawler.WithFetcher(&crawler.ChromeFetcher{
+			WaitSelector: opts.renderWait,
+			Timeout:      opts.renderTimeout,
+		}))
+	}
+	var c *crawler.Crawler
+	if cfg != nil {
+		c, err = crawler.NewFromConfig(cfg, log, crawlerOpts...)
+		if err != nil {
+			return err
+		}
+	} else {
+		c = crawler.New(startUrl, destDir, defaultTimeOut, log, crawlerOpts...)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 	crawlerErrors := make(chan error, 1)
 	go func() {
-		crawlerErrors <- c.Run()
+		crawlerErrors <- c.Run(ctx)
 	}()
 	select {
 	case err := <-crawlerErrors:
 		return errors.Wrap(err, "server error")
 	case sig := <-shutdown:
 		log.Printf("main: %v: Start shutdown", sig)
+		cancel()
+		return errors.Wrap(<-crawlerErrors, "server error")
+	}
+}
+
+// newStorage builds the storage backend selected by format: "mirror"
+// (the default, aliased as "dir") writes a browsable directory tree
+// under destDir, "warc" appends WARC 1.1 records to warcFile, and
+// "both" writes to both at once. maxWarcFileSize, if > 0, rotates the
+// WARC file once it reaches that many bytes.
+func newStorage(format, destDir, warcFile, userAgent string, maxWarcFileSize int64) (storage.Storage, error) {
+	var warcOpts []storage.WarcOption
+	if maxWarcFileSize > 0 {
+		warcOpts = append(warcOpts, storage.WithMaxFileSize(maxWarcFileSize))
+	}
+	switch format {
+	case "", "mirror", "dir":
+		return storage.NewFileStorage(destDir), nil
+	case "warc":
+		return storage.NewWarcStorage(warcFile, userAgent, warcOpts...)
+	case "both":
+		warc, err := storage.NewWarcStorage(warcFile, userAgent, warcOpts...)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewMultiStorage(storage.NewFileStorage(destDir), warc), nil
+	default:
+		return nil, errors.Errorf("unknown format %q, must be one of: mirror, dir, warc, both", format)
 	}
-	return nil
 }
 
 func main() {
 	log := log.New(os.Stdout, "WEB CRAWLER : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
-	startUrl := flag.String("u", "", "url")
-	destDir := flag.String("d", "", "dest dir")
+	startUrl := flag.String("u", "", "url, overrides the seed list in -config if both are given")
+	destDir := flag.String("d", "", "dest dir, overrides dest_dir in -config if both are given")
+	configPath := flag.String("config", "", "path to a JSON or YAML config file describing seeds, request/scope settings, a domain blacklist, and what to extract via its search section; -u and -d still work as shortcuts on top of it")
+	userAgent := flag.String("user-agent", "mirroring-web-crawler", "user agent sent with requests and matched against robots.txt")
+	minDelay := flag.Duration("min-delay", 0, "minimum delay between requests to the same host")
+	format := flag.String("format", "mirror", "output format: mirror (or dir), warc, or both")
+	warcFile := flag.String("output", "crawl.warc.gz", "warc file to write to when -format=warc or -format=both")
+	maxWarcFileSize := flag.Int64("max-warc-size", 0, "rotate to a new, sequentially-numbered warc file once the current one reaches this many bytes, 0 for no rotation, only used with -format=warc or -format=both")
+	maxDepth := flag.Int("max-depth", -1, "maximum number of hops from the start url to follow, -1 for unlimited")
+	maxPages := flag.Int("max-pages", 0, "maximum number of pages to visit, 0 for unlimited")
+	includeRegexp := flag.String("include-regexp", "", "only crawl urls matching this regexp")
+	excludeRegexp := flag.String("exclude-regexp", "", "skip urls matching this regexp")
+	concurrency := flag.Int("concurrency", 10, "maximum number of pages fetched in parallel")
+	checkpoint := flag.String("checkpoint", "", "path to a checkpoint file tracking crawl state, disabled if empty")
+	revalidate := flag.Bool("revalidate", false, "with -checkpoint, re-check already fetched urls with conditional requests instead of skipping them")
+	resume := flag.Bool("resume", true, "with -checkpoint, pick up an interrupted crawl from its recorded progress instead of starting over")
+	rewriteAssets := flag.Bool("rewrite-assets", false, "download images, stylesheets, scripts, and CSS url(...) references and rewrite pages to reference them locally, so the mirror is browsable via file://")
+	assetConcurrency := flag.Int("asset-concurrency", 0, "maximum number of assets fetched in parallel, 0 for the assets package default, only used with -rewrite-assets")
+	sitemap := flag.Bool("sitemap", false, "seed the crawl from the site's sitemap.xml and any feeds linked from the start url, in addition to following links as usual")
+	since := flag.String("since", "", "with -sitemap, only seed urls with no lastmod/pubDate/updated timestamp or one after this RFC3339 time")
+	sitemapOnly := flag.Bool("sitemap-only", false, "with -sitemap, crawl only the seeded urls and do not follow any links from them")
+	sources := flag.String("sources", "", "comma-separated external seed sources to enable before crawling: sitemap, wayback, commoncrawl, vt")
+	vtApiKey := flag.String("vt-api-key", "", "VirusTotal API key, required when -sources includes vt")
+	rpsPerHost := flag.Float64("rps-per-host", 0, "maximum requests per second to any single host, 0 for unlimited, independent of -concurrency and -min-delay")
+	maxBandwidth := flag.Int("max-bps", 0, "maximum total outbound bytes per second across every host, 0 for unlimited")
+	renderJS := flag.Bool("render-js", false, "fetch pages with a headless Chrome instance instead of a plain GET, for sites that build content with JavaScript; disables conditional requests and -revalidate's 304 shortcut for the whole crawl")
+	renderWait := flag.String("render-wait-selector", "", "with -render-js, a CSS selector to wait for before reading the page, for content inserted after the load event; if empty, only the load event is awaited")
+	renderTimeout := flag.Duration("render-timeout", 30*time.Second, "with -render-js, maximum time to wait for a single page to render")
 	flag.Parse()
-	if err := run(log, *startUrl, *destDir); err != nil {
+	var sinceTime time.Time
+	if *since != "" {
+		var err error
+		sinceTime, err = time.Parse(time.RFC3339, *since)
+		if err != nil {
+			log.Printf("main: invalid -since %q: %v", *since, err)
+			os.Exit(1)
+		}
+	}
+	opts := options{
+		userAgent:        *userAgent,
+		minDelay:         *minDelay,
+		maxDepth:         *maxDepth,
+		maxPages:         *maxPages,
+		includeRegexp:    *includeRegexp,
+		excludeRegexp:    *excludeRegexp,
+		concurrency:      *concurrency,
+		checkpoint:       *checkpoint,
+		revalidate:       *revalidate,
+		resume:           *resume,
+		maxWarcFileSize:  *maxWarcFileSize,
+		rewriteAssets:    *rewriteAssets,
+		assetConcurrency: *assetConcurrency,
+		sitemap:          *sitemap,
+		since:            sinceTime,
+		sitemapOnly:      *sitemapOnly,
+		configPath:       *configPath,
+		sources:          *sources,
+		vtApiKey:         *vtApiKey,
+		rpsPerHost:       *rpsPerHost,
+		maxBandwidth:     *maxBandwidth,
+		renderJS:         *renderJS,
+		renderWait:       *renderWait,
+		renderTimeout:    *renderTimeout,
+	}
+	if err := run(log, *startUrl, *destDir, *format, *warcFile, opts); err != nil {
 		log.Println(err)
 		os.Exit(1)
 	}
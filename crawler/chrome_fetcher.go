@@ -0,0 +1,65 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/pkg/errors"
+)
+
+// defaultChromeTimeout bounds how long ChromeFetcher waits for a page,
+// including the time spent waiting for WaitSelector, to finish loading.
+const defaultChromeTimeout = 30 * time.Second
+
+// ChromeFetcher fetches a url by loading it in a headless Chrome/Chromium
+// instance via chromedp and returning the page's rendered HTML, so pages
+// that build their content with JavaScript are captured the same way a
+// browser's user would see them. It has no equivalent to conditional GET:
+// every Fetch re-renders the page in full.
+type ChromeFetcher struct {
+	// WaitSelector, if non-empty, is a CSS selector Fetch waits to
+	// become visible before reading the page, for pages whose content
+	// is inserted asynchronously after the initial load event. If
+	// empty, Fetch only waits for the page's load event to fire.
+	WaitSelector string
+
+	// Timeout bounds a single Fetch call, defaulting to
+	// defaultChromeTimeout if zero.
+	Timeout time.Duration
+}
+
+// Fetch implements Fetcher. It ignores etag and lastModified: Chrome
+// has no conditional-navigation equivalent, so every call re-renders
+// the page in full and reports it as http.StatusOK, since chromedp
+// doesn't surface the navigation's actual response status.
+func (f *ChromeFetcher) Fetch(ctx context.Context, url, etag, lastModified string) (int, []byte, string, http.Header, error) {
+	timeout := f.Timeout
+	if timeout == 0 {
+		timeout = defaultChromeTimeout
+	}
+	ctx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+
+	actions := []chromedp.Action{chromedp.Navigate(url)}
+	if f.WaitSelector != "" {
+		actions = append(actions, chromedp.WaitVisible(f.WaitSelector, chromedp.ByQuery))
+	} else {
+		actions = append(actions, chromedp.WaitReady("body", chromedp.ByQuery))
+	}
+	var html string
+	actions = append(actions, chromedp.OuterHTML("html", &html, chromedp.ByQuery))
+	var finalURL string
+	actions = append(actions, chromedp.Location(&finalURL))
+
+	if err := chromedp.Run(ctx, actions...); err != nil {
+		return 0, nil, "", nil, errors.Wrapf(err, "rendering %v in headless chrome", url)
+	}
+	return http.StatusOK, []byte(html), finalURL, nil, nil
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tcmsoftware/mirroring-web-crawler/search"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigTimeout is used when a Config's Request.Timeout is
+// unset.
+const defaultConfigTimeout = 10 * time.Second
+
+// Config is the structured, file-based alternative to building up a
+// Crawler option by option: the seed URLs, where to write the mirror,
+// how polite and wide to be, and what to specifically extract along
+// the way. Load one with LoadConfig and turn it into a Crawler with
+// NewFromConfig.
+type Config struct {
+	// Seeds lists the URLs the crawl starts from. The first becomes the
+	// Crawler's start URL; any further ones are queued alongside it.
+	Seeds   []string `json:"seeds" yaml:"seeds"`
+	DestDir string   `json:"dest_dir" yaml:"dest_dir"`
+
+	Request RequestConfig `json:"request" yaml:"request"`
+	Scope   ScopeConfig   `json:"scope" yaml:"scope"`
+
+	// Blacklist excludes these domains, and any of their subdomains,
+	// from the crawl, regardless of Scope.
+	Blacklist []string `json:"blacklist,omitempty" yaml:"blacklist,omitempty"`
+
+	// Search, if set, enables content extraction alongside the mirror.
+	Search *SearchConfig `json:"search,omitempty" yaml:"search,omitempty"`
+}
+
+// RequestConfig controls how each HTTP request is made.
+type RequestConfig struct {
+	Timeout            time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	UserAgent          string        `json:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+	Delay              time.Duration `json:"delay,omitempty" yaml:"delay,omitempty"`
+	Concurrency        int           `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+	ProxyURL           string        `json:"proxy_url,omitempty" yaml:"proxy_url,omitempty"`
+	InsecureSkipVerify bool          `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// ScopeConfig bounds what the crawl is allowed to reach. A MaxDepth or
+// MaxPages of 0 means unlimited. For MaxPages this matches
+// WithMaxPages; for MaxDepth it differs from WithMaxDepth, where 0
+// limits the crawl to the seeds and only a negative depth is unlimited.
+type ScopeConfig struct {
+	AllowedDomains    []string `json:"allowed_domains,omitempty" yaml:"allowed_domains,omitempty"`
+	IncludeSubdomains bool     `json:"include_subdomains,omitempty" yaml:"include_subdomains,omitempty"`
+	URLPrefix         string   `json:"url_prefix,omitempty" yaml:"url_prefix,omitempty"`
+	IncludeRegexp     string   `json:"include_regexp,omitempty" yaml:"include_regexp,omitempty"`
+	ExcludeRegexp     string   `json:"exclude_regexp,omitempty" yaml:"exclude_regexp,omitempty"`
+	MaxDepth          int      `json:"max_depth,omitempty" yaml:"max_depth,omitempty"`
+	MaxPages          int      `json:"max_pages,omitempty" yaml:"max_pages,omitempty"`
+}
+
+// SearchConfig describes what a crawl should extract and save as it
+// goes, separately from the mirrored pages themselves.
+type SearchConfig struct {
+	PageRegexp      string   `json:"page_regexp,omitempty" yaml:"page_regexp,omitempty"`
+	ImageExtensions []string `json:"image_extensions,omitempty" yaml:"image_extensions,omitempty"`
+	Selectors       []string `json:"selectors,omitempty" yaml:"selectors,omitempty"`
+	// OutputFile is where matches are appended as JSON lines. Defaults
+	// to "search-results.jsonl" inside the Config's DestDir.
+	OutputFile string `json:"output_file,omitempty" yaml:"output_file,omitempty"`
+}
+
+// LoadConfig reads and parses a Config from path. A ".yml"/".yaml"
+// extension is parsed as YAML; every other extension is parsed as
+// JSON.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading config file %s", path)
+	}
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, errors.Wrapf(err, "parsing yaml config %s", path)
+		}
+	default:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, errors.Wrapf(err, "parsing json config %s", path)
+		}
+	}
+	return &cfg, nil
+}
+
+// Validate reports whether cfg has at least one seed URL and a
+// destination directory, and that its regexps compile.
+func (cfg *Config) Validate() error {
+	if len(cfg.Seeds) == 0 {
+		return errors.New("config: at least one seed url is required")
+	}
+	if cfg.DestDir == "" {
+		return errors.New("config: dest_dir is required")
+	}
+	if cfg.Scope.IncludeRegexp != "" {
+		if _, err := regexp.Compile(cfg.Scope.IncludeRegexp); err != nil {
+			return errors.Wrap(err, "compiling scope.include_regexp")
+		}
+	}
+	if cfg.Scope.ExcludeRegexp != "" {
+		if _, err := regexp.Compile(cfg.Scope.ExcludeRegexp); err != nil {
+			return errors.Wrap(err, "compiling scope.exclude_regexp")
+		}
+	}
+	if cfg.Search != nil && cfg.Search.PageRegexp != "" {
+		if _, err := regexp.Compile(cfg.Search.PageRegexp); err != nil {
+			return errors.Wrap(err, "compiling search.page_regexp")
+		}
+	}
+	return nil
+}
+
+// NewFromConfig builds a Crawler from cfg: its first seed URL becomes
+// the start URL passed to New, any further seeds are queued alongside
+// it via WithSeeds, and the request/scope/blacklist/search sections are
+// translated into the matching Options. opts are applied last, on top
+// of cfg's, for flags that have no place in a Config, such as
+// WithStorage or WithCheckpoint.
+func NewFromConfig(cfg *Config, logger *log.Logger, opts ...Option) (*Crawler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	configOpts := []Option{
+		WithMaxPages(cfg.Scope.MaxPages),
+	}
+	if cfg.Scope.MaxDepth != 0 {
+		configOpts = append(configOpts, WithMaxDepth(cfg.Scope.MaxDepth))
+	}
+	if cfg.Request.UserAgent != "" {
+		configOpts = append(configOpts, WithUserAgent(cfg.Request.UserAgent))
+	}
+	if cfg.Request.Delay > 0 {
+		configOpts = append(configOpts, WithMinDelay(cfg.Request.Delay))
+	}
+	if cfg.Request.Concurrency > 0 {
+		configOpts = append(configOpts, WithConcurrency(cfg.Request.Concurrency))
+	}
+	if cfg.Request.ProxyURL != "" {
+		configOpts = append(configOpts, WithProxy(cfg.Request.ProxyURL))
+	}
+	if cfg.Request.InsecureSkipVerify {
+		configOpts = append(configOpts, WithInsecureSkipVerify(true))
+	}
+	if cfg.Scope.IncludeRegexp != "" {
+		configOpts = append(configOpts, WithIncludeRegexp(regexp.MustCompile(cfg.Scope.IncludeRegexp)))
+	}
+	if cfg.Scope.ExcludeRegexp != "" {
+		configOpts = append(configOpts, WithExcludeRegexp(regexp.MustCompile(cfg.Scope.ExcludeRegexp)))
+	}
+	if cfg.Scope.URLPrefix != "" {
+		configOpts = append(configOpts, WithURLPrefix(cfg.Scope.URLPrefix))
+	}
+	if len(cfg.Scope.AllowedDomains) > 0 {
+		configOpts = append(configOpts, WithAllowedDomains(cfg.Scope.AllowedDomains, cfg.Scope.IncludeSubdomains))
+	}
+	if len(cfg.Blacklist) > 0 {
+		configOpts = append(configOpts, WithDomainBlacklist(cfg.Blacklist))
+	}
+	if len(cfg.Seeds) > 1 {
+		configOpts = append(configOpts, WithSeeds(cfg.Seeds[1:]))
+	}
+	if cfg.Search != nil {
+		searchCfg := search.Config{
+			ImageExtensions: cfg.Search.ImageExtensions,
+			Selectors:       cfg.Search.Selectors,
+		}
+		if cfg.Search.PageRegexp != "" {
+			searchCfg.PageRegexp = regexp.MustCompile(cfg.Search.PageRegexp)
+		}
+		outputFile := cfg.Search.OutputFile
+		if outputFile == "" {
+			outputFile = filepath.Join(cfg.DestDir, "search-results.jsonl")
+		}
+		configOpts = append(configOpts, WithSearch(searchCfg, outputFile))
+	}
+
+	timeout := cfg.Request.Timeout
+	if timeout <= 0 {
+		timeout = defaultConfigTimeout
+	}
+	return New(cfg.Seeds[0], cfg.DestDir, timeout, logger, append(configOpts, opts...)...), nil
+}
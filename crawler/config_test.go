@@ -0,0 +1,159 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	return path
+}
+
+func Test_LoadConfig_Json(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+		"seeds": ["https://example.com"],
+		"dest_dir": "out",
+		"request": {"user_agent": "testbot", "concurrency": 5},
+		"scope": {"allowed_domains": ["example.com"], "max_depth": 2}
+	}`)
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com"}, cfg.Seeds)
+	require.Equal(t, "out", cfg.DestDir)
+	require.Equal(t, "testbot", cfg.Request.UserAgent)
+	require.Equal(t, 5, cfg.Request.Concurrency)
+	require.Equal(t, []string{"example.com"}, cfg.Scope.AllowedDomains)
+	require.Equal(t, 2, cfg.Scope.MaxDepth)
+}
+
+func Test_LoadConfig_Yaml(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+seeds:
+  - https://example.com
+dest_dir: out
+blacklist:
+  - ads.example.com
+search:
+  page_regexp: "article"
+  image_extensions: [".jpg", ".png"]
+  selectors: ["h1.title"]
+`)
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com"}, cfg.Seeds)
+	require.Equal(t, []string{"ads.example.com"}, cfg.Blacklist)
+	require.NotNil(t, cfg.Search)
+	require.Equal(t, "article", cfg.Search.PageRegexp)
+	require.Equal(t, []string{".jpg", ".png"}, cfg.Search.ImageExtensions)
+}
+
+func Test_LoadConfig_MissingFile(t *testing.T) {
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+func Test_Config_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name:    "no seeds",
+			cfg:     Config{DestDir: "out"},
+			wantErr: true,
+		},
+		{
+			name:    "no dest dir",
+			cfg:     Config{Seeds: []string{"https://example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "valid",
+			cfg:     Config{Seeds: []string{"https://example.com"}, DestDir: "out"},
+			wantErr: false,
+		},
+		{
+			name: "invalid include regexp",
+			cfg: Config{
+				Seeds:   []string{"https://example.com"},
+				DestDir: "out",
+				Scope:   ScopeConfig{IncludeRegexp: "("},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid search page regexp",
+			cfg: Config{
+				Seeds:   []string{"https://example.com"},
+				DestDir: "out",
+				Search:  &SearchConfig{PageRegexp: "("},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_NewFromConfig(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags)
+	st := newFakeStorage()
+	cfg := &Config{
+		Seeds:   []string{"https://example.com", "https://example.com/other"},
+		DestDir: t.TempDir(),
+		Request: RequestConfig{UserAgent: "testbot", Concurrency: 7},
+		Scope: ScopeConfig{
+			AllowedDomains:    []string{"example.com"},
+			IncludeSubdomains: true,
+			MaxDepth:          3,
+		},
+		Blacklist: []string{"ads.example.com"},
+	}
+	c, err := NewFromConfig(cfg, logger, WithStorage(st))
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", c.startUrl)
+	require.Equal(t, "testbot", c.userAgent)
+	require.Equal(t, 7, c.concurrency)
+	require.Equal(t, 3, c.maxDepth)
+	require.Equal(t, []string{"example.com"}, c.allowedDomains)
+	require.True(t, c.includeSubdomains)
+	require.Equal(t, []string{"ads.example.com"}, c.domainBlacklist)
+	require.Equal(t, []string{"https://example.com/other"}, c.extraSeeds)
+	require.Same(t, st, c.storage)
+}
+
+func Test_NewFromConfig_DefaultMaxDepth(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags)
+	st := newFakeStorage()
+	cfg := &Config{
+		Seeds:   []string{"https://example.com"},
+		DestDir: t.TempDir(),
+	}
+	c, err := NewFromConfig(cfg, logger, WithStorage(st))
+	require.NoError(t, err)
+	require.Equal(t, unlimitedDepth, c.maxDepth)
+}
+
+func Test_NewFromConfig_InvalidConfig(t *testing.T) {
+	_, err := NewFromConfig(&Config{}, log.New(os.Stdout, "", 0))
+	require.Error(t, err)
+}
@@ -4,110 +4,189 @@
 package crawler
 
 import (
-	"io/fs"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io"
 	"log"
 	"net/http"
-	goUrl "net/url"
-	"os"
-	"path"
+	neturl "net/url"
+	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/pkg/errors"
+	"github.com/tcmsoftware/mirroring-web-crawler/assets"
+	"github.com/tcmsoftware/mirroring-web-crawler/checkpoint"
+	"github.com/tcmsoftware/mirroring-web-crawler/frontier"
+	"github.com/tcmsoftware/mirroring-web-crawler/robots"
+	"github.com/tcmsoftware/mirroring-web-crawler/search"
+	"github.com/tcmsoftware/mirroring-web-crawler/seeds"
+	"github.com/tcmsoftware/mirroring-web-crawler/storage"
+	"golang.org/x/time/rate"
 )
 
+// unlimitedDepth/unlimitedPages are the defaults passed to the
+// Frontier when WithMaxDepth/WithMaxPages are not used.
+const (
+	unlimitedDepth = -1
+	unlimitedPages = 0
+)
+
+// defaultUserAgent is sent with every request unless overridden with
+// WithUserAgent, and is also the identity robots.txt rules are matched
+// against.
+const defaultUserAgent = "mirroring-web-crawler"
+
+// defaultConcurrency is the worker pool size used when WithConcurrency
+// is not given.
+const defaultConcurrency = 10
+
 // For ease of unit testing, so
 // we can inject everything we need to.
 var (
-	get = func(httpClient *http.Client, url string) (*http.Response, error) {
-		return httpClient.Get(url)
+	get = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+		return httpClient.Do(req)
 	}
 	goqueryNewDocumentFromReader = goquery.NewDocumentFromReader
-	parseUrl                     = goUrl.Parse
-	osStat                       = os.Stat
-	osMkdirAll                   = os.MkdirAll
-	osCreate                     = os.Create
-	getDocHtml                   = func(doc *goquery.Document) (string, error) {
-		return doc.Html()
-	}
-	writeStringToFile = func(f *os.File, data string) (int, error) {
-		return f.WriteString(data)
-	}
-	getUrl = func(httpClient *http.Client, url string) (*http.Response, error) {
-		resp, err := get(httpClient, url)
+	readAll                      = io.ReadAll
+	timeNow                      = time.Now
+	getUrl                       = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		resp, err := get(ctx, httpClient, url, userAgent, etag, lastModified)
 		if err != nil {
 			return nil, errors.Wrapf(err, "making get request to %v", url)
 		}
 		return resp, nil
 	}
-	parseResponse = func(url string, resp *http.Response) (*goquery.Document, error) {
-		defer resp.Body.Close()
-		doc, err := goqueryNewDocumentFromReader(resp.Body)
+	parseResponse = func(url string, body []byte) (*goquery.Document, error) {
+		doc, err := goqueryNewDocumentFromReader(bytes.NewReader(body))
 		if err != nil {
 			return nil, errors.Wrapf(err, "parsing response from url %v", url)
 		}
 		return doc, nil
 	}
-	getPagePath = func(destDir, url string) (string, error) {
-		parsedUrl, err := parseUrl(url)
-		if err != nil {
-			return "", errors.Wrapf(err, "parsing url %v", url)
-		}
-		if strings.HasSuffix(parsedUrl.Path, "/") {
-			return path.Join(destDir, parsedUrl.Host, parsedUrl.Path, "index.html"), nil
-		} else {
-			return path.Join(destDir, parsedUrl.Host, parsedUrl.Path), nil
+	savePage = func(st storage.Storage, url string, statusCode int, headers http.Header, body []byte, log *log.Logger) error {
+		if st.Exists(url) {
+			log.Printf("%s already exists, skipping\n", url)
+			return nil
 		}
+		return st.Put(url, statusCode, headers, body)
 	}
-	saveToDisk = func(url string, pagePath string, doc *goquery.Document) error {
-		file, err := osCreate(pagePath)
-		if err != nil {
-			return errors.Wrapf(err, "creating file for %s", url)
-		}
-		defer file.Close()
-		html, err := getDocHtml(doc)
-		if err != nil {
-			return errors.Wrapf(err, "converting %s to HTML", url)
-		}
-		_, err = writeStringToFile(file, html)
+	contentHash = func(body []byte) string {
+		sum := sha256.Sum256(body)
+		return hex.EncodeToString(sum[:])
+	}
+	renderDoc = func(doc *goquery.Document) ([]byte, error) {
+		html, err := doc.Html()
 		if err != nil {
-			return errors.Wrapf(err, "writing HTML file for %s", url)
+			return nil, err
 		}
-		return nil
+		return []byte(html), nil
 	}
-	savePage = func(destDir, url string, doc *goquery.Document, log *log.Logger) error {
-		pagePath, err := getPagePath(destDir, url)
-		if err != nil {
-			return err
+	discoverSeeds = seeds.Discover
+	// processUrl fetches url via fetcher (HTTPFetcher if the Crawler was
+	// not built with WithFetcher) and persists it to st, and, if cp is
+	// non-nil, records the outcome so a later run can resume or
+	// revalidate. When cp already has a cached ETag/Last-Modified for
+	// url, they are sent as conditional request headers; a 304 response
+	// is treated as already up to date and nothing is re-fetched or
+	// re-saved, though a fetcher with no conditional-request equivalent,
+	// like ChromeFetcher, is free to ignore them and never report one.
+	// When assetRewriter is non-nil, the page's assets are downloaded
+	// and its markup rewritten to reference them locally before it is
+	// saved. hostLimiter and bandwidthLimiter, if non-nil, throttle
+	// requests per host and total outbound bytes respectively, on top of
+	// whatever delay politeness already enforces.
+	processUrl = func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hostLimiter *hostLimiter, bandwidthLimiter *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
+		if politeness != nil {
+			allowed, err := politeness.Allowed(url)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				log.Printf("%s disallowed by robots.txt, skipping\n", url)
+				return nil, nil
+			}
+			if err := politeness.Wait(ctx, url); err != nil {
+				return nil, err
+			}
 		}
-		if _, err := osStat(pagePath); err == nil {
-			log.Printf("%s already exists, skipping\n", url)
-			return nil
+		if hostLimiter != nil {
+			if err := hostLimiter.wait(ctx, url); err != nil {
+				return nil, err
+			}
 		}
-		err = osMkdirAll(path.Dir(pagePath), fs.ModePerm)
-		if err != nil {
-			return errors.Wrapf(err, "creating directory for %s", url)
+		if fetcher == nil {
+			fetcher = &HTTPFetcher{HTTPClient: httpClient, UserAgent: userAgent}
 		}
-		if err := saveToDisk(url, pagePath, doc); err != nil {
-			return err
+		var etag, lastModified string
+		if cp != nil {
+			if entry, ok := cp.Get(url); ok {
+				etag, lastModified = entry.ETag, entry.LastModified
+			}
 		}
-		return nil
-	}
-	processUrl = func(httpClient *http.Client, destDir, url string, log *log.Logger) (*goquery.Document, error) {
-		response, err := getUrl(httpClient, url)
+		statusCode, body, _, headers, err := fetcher.Fetch(ctx, url, etag, lastModified)
 		if err != nil {
 			return nil, err
 		}
-		doc, err := parseResponse(url, response)
+		if statusCode == http.StatusNotModified {
+			log.Printf("%s not modified, skipping\n", url)
+			if cp != nil {
+				if entry, ok := cp.Get(url); ok {
+					entry.FetchedAt = timeNow()
+					cp.Set(url, entry)
+				}
+			}
+			return nil, nil
+		}
+		if bandwidthLimiter != nil && len(body) > 0 {
+			if len(body) > bandwidthLimiter.Burst() {
+				log.Printf("%s: response body larger than the configured max bandwidth burst, not rate-limiting this fetch\n", url)
+			} else if err := bandwidthLimiter.WaitN(ctx, len(body)); err != nil {
+				return nil, err
+			}
+		}
+		doc, err := parseResponse(url, body)
 		if err != nil {
 			return nil, err
 		}
-		err = savePage(destDir, url, doc, log)
-		if err != nil {
+		if assetRewriter != nil {
+			if err := assetRewriter.Process(ctx, httpClient, userAgent, doc, url); err != nil {
+				log.Printf("rewriting assets for %s: %v\n", url, err)
+			} else if rendered, err := renderDoc(doc); err == nil {
+				body = rendered
+			} else {
+				log.Printf("rendering rewritten document for %s: %v\n", url, err)
+			}
+		}
+		if err := savePage(st, url, statusCode, headers, body, log); err != nil {
 			return nil, err
 		}
+		if cp != nil {
+			cp.Set(url, checkpoint.Entry{
+				Status:       checkpoint.StatusOK,
+				ContentHash:  contentHash(body),
+				ETag:         headers.Get("ETag"),
+				LastModified: headers.Get("Last-Modified"),
+				FetchedAt:    timeNow(),
+			})
+		}
 		return doc, nil
 	}
 	fromSameDomain = func(startUrl, link string) bool {
@@ -119,69 +198,603 @@ var (
 		}
 		return link
 	}
-	getNextUrls = func(c *Crawler, doc *goquery.Document) []string {
-		var nextUrls []string
+	getNextUrls = func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+		var nextItems []frontier.Item
 		doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+			if rel := s.AttrOr("rel", ""); strings.Contains(rel, "nofollow") {
+				return
+			}
 			href, exists := s.Attr("href")
 			if exists && fromSameDomain(c.startUrl, href) {
 				childUrl := getAbsoluteUrl(c.startUrl, href)
-				c.mu.Lock()
-				if !c.visitedUrls[childUrl] {
-					c.visitedUrls[childUrl] = true
-					nextUrls = append(nextUrls, childUrl)
+				if c.frontier.Add(childUrl, depth+1) {
+					nextItems = append(nextItems, frontier.Item{URL: childUrl, Depth: depth + 1})
 				}
-				c.mu.Unlock()
 			}
 		})
-		return nextUrls
+		return nextItems
 	}
 )
 
 // Crawler is a recursive web crawler.
 type Crawler struct {
-	log         *log.Logger
-	startUrl    string
-	destDir     string
-	httpClient  *http.Client
-	visitedUrls map[string]bool
-	mu          sync.Mutex
+	log                *log.Logger
+	startUrl           string
+	destDir            string
+	httpClient         *http.Client
+	userAgent          string
+	minDelay           time.Duration
+	politeness         *robots.Policy
+	storage            storage.Storage
+	frontier           *frontier.Frontier
+	maxDepth           int
+	maxPages           int
+	includeRegexp      *regexp.Regexp
+	excludeRegexp      *regexp.Regexp
+	concurrency        int
+	checkpointPath     string
+	revalidate         bool
+	resume             bool
+	checkpoint         *checkpoint.Store
+	rewriteAssets      bool
+	assetConcurrency   int
+	assetRewriter      *assets.Rewriter
+	useSitemap         bool
+	since              time.Time
+	sitemapOnly        bool
+	extraSeeds         []string
+	proxyURL           string
+	insecureSkipVerify bool
+	allowedDomains     []string
+	includeSubdomains  bool
+	domainBlacklist    []string
+	urlPrefix          string
+	searchConfig       *search.Config
+	searchOutputFile   string
+	searchRecorder     *search.Recorder
+	seeders            []Seeder
+	rpsPerHost         float64
+	maxBandwidth       int
+	hostLimiter        *hostLimiter
+	bandwidthLimiter   *rate.Limiter
+	fetcher            Fetcher
+	pipeline           *Pipeline
+}
+
+// Option configures optional behavior on a Crawler created via New.
+type Option func(c *Crawler)
+
+// WithUserAgent sets the User-Agent string sent with every request and
+// matched against robots.txt rules. Defaults to defaultUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Crawler) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithMinDelay sets the minimum delay observed between requests to the
+// same host, even if the host's robots.txt does not specify a
+// Crawl-delay. Defaults to 0.
+func WithMinDelay(minDelay time.Duration) Option {
+	return func(c *Crawler) {
+		c.minDelay = minDelay
+	}
+}
+
+// WithStorage sets the backend pages are persisted to. Defaults to a
+// storage.FileStorage rooted at destDir.
+func WithStorage(s storage.Storage) Option {
+	return func(c *Crawler) {
+		c.storage = s
+	}
+}
+
+// WithMaxDepth limits how many hops away from the start URL the
+// crawler will follow links. Defaults to unlimited.
+func WithMaxDepth(maxDepth int) Option {
+	return func(c *Crawler) {
+		c.maxDepth = maxDepth
+	}
+}
+
+// WithMaxPages limits how many distinct pages the crawler will visit
+// in total. Defaults to unlimited.
+func WithMaxPages(maxPages int) Option {
+	return func(c *Crawler) {
+		c.maxPages = maxPages
+	}
+}
+
+// WithIncludeRegexp restricts the crawl to URLs matching re.
+func WithIncludeRegexp(re *regexp.Regexp) Option {
+	return func(c *Crawler) {
+		c.includeRegexp = re
+	}
+}
+
+// WithExcludeRegexp skips any URL matching re.
+func WithExcludeRegexp(re *regexp.Regexp) Option {
+	return func(c *Crawler) {
+		c.excludeRegexp = re
+	}
+}
+
+// WithConcurrency bounds the number of pages fetched in parallel.
+// Defaults to defaultConcurrency.
+func WithConcurrency(concurrency int) Option {
+	return func(c *Crawler) {
+		c.concurrency = concurrency
+	}
+}
+
+// WithCheckpoint enables resumable crawls: after every fetch, progress
+// is recorded to the JSON file at path, and a subsequent Run against
+// the same path skips URLs already fetched successfully instead of
+// re-downloading them.
+func WithCheckpoint(path string) Option {
+	return func(c *Crawler) {
+		c.checkpointPath = path
+	}
+}
+
+// WithRevalidate changes how a checkpoint is used on a subsequent Run:
+// instead of skipping URLs already marked done, the crawler re-queues
+// them and sends their cached ETag/Last-Modified as conditional request
+// headers, so unchanged pages are cheaply confirmed via a 304 instead
+// of skipped outright. Has no effect without WithCheckpoint.
+func WithRevalidate(revalidate bool) Option {
+	return func(c *Crawler) {
+		c.revalidate = revalidate
+	}
+}
+
+// WithResume controls whether a checkpoint's recorded progress is used
+// to pick up an interrupted crawl: with resume, URLs already done are
+// skipped (or revalidated) and any items still queued are seeded first;
+// without it, the checkpoint is still loaded and kept up to date, but
+// the crawl starts over from the start URL. Defaults to true. Has no
+// effect without WithCheckpoint.
+func WithResume(resume bool) Option {
+	return func(c *Crawler) {
+		c.resume = resume
+	}
+}
+
+// WithAssetRewriting downloads the images, stylesheets, scripts, and
+// CSS url(...) references a page points to, and rewrites those
+// references to their relative on-disk path, so the mirror is
+// browsable offline via file://. Disabled by default.
+func WithAssetRewriting(enabled bool) Option {
+	return func(c *Crawler) {
+		c.rewriteAssets = enabled
+	}
+}
+
+// WithAssetConcurrency bounds how many assets are downloaded in
+// parallel. Only takes effect with WithAssetRewriting. Defaults to the
+// assets package's own default.
+func WithAssetConcurrency(concurrency int) Option {
+	return func(c *Crawler) {
+		c.assetConcurrency = concurrency
+	}
+}
+
+// WithSitemap pre-populates the frontier, before Run's link-following
+// traversal begins, with the URLs advertised by the site's
+// /sitemap.xml (following any sitemapindex recursively) and by any
+// RSS/Atom feeds linked from the start page. Combine with WithSince to
+// seed only newly-published pages instead of the whole sitemap.
+// Disabled by default.
+func WithSitemap(enabled bool) Option {
+	return func(c *Crawler) {
+		c.useSitemap = enabled
+	}
+}
+
+// WithSince restricts the seeding enabled by WithSitemap to entries
+// with no lastmod/pubDate/updated timestamp or one after since.
+// Defaults to the zero Time, which includes everything. Has no effect
+// without WithSitemap.
+func WithSince(since time.Time) Option {
+	return func(c *Crawler) {
+		c.since = since
+	}
+}
+
+// WithSitemapOnly, combined with WithSitemap, skips HTML link
+// discovery entirely: only the URLs seeded from the sitemap and feeds
+// are crawled, and none of the links on those pages are followed. Has
+// no effect without WithSitemap.
+func WithSitemapOnly(enabled bool) Option {
+	return func(c *Crawler) {
+		c.sitemapOnly = enabled
+	}
+}
+
+// WithSeeds queues additional start URLs alongside the one passed to
+// New, so a single crawl can begin from more than one page. Link
+// discovery's same-domain check still anchors off the URL passed to
+// New: on every page, only links that are relative or start with that
+// URL are followed, so links to other hosts are never followed, and
+// WithAllowedDomains can only narrow this further.
+func WithSeeds(urls []string) Option {
+	return func(c *Crawler) {
+		c.extraSeeds = urls
+	}
+}
+
+// WithProxy routes every request through the HTTP or SOCKS5 proxy at
+// proxyURL. An invalid proxyURL is logged and ignored rather than
+// failing New, which has no error return.
+func WithProxy(proxyURL string) Option {
+	return func(c *Crawler) {
+		c.proxyURL = proxyURL
+	}
+}
+
+// WithInsecureSkipVerify disables TLS certificate verification.
+// Intended for crawling internal sites with self-signed certificates;
+// leave disabled otherwise.
+func WithInsecureSkipVerify(skip bool) Option {
+	return func(c *Crawler) {
+		c.insecureSkipVerify = skip
+	}
+}
+
+// WithAllowedDomains restricts the crawl to the given domains (and,
+// with includeSubdomains, any of their subdomains), on top of whatever
+// WithIncludeRegexp/WithExcludeRegexp already filter.
+func WithAllowedDomains(domains []string, includeSubdomains bool) Option {
+	return func(c *Crawler) {
+		c.allowedDomains = domains
+		c.includeSubdomains = includeSubdomains
+	}
+}
+
+// WithDomainBlacklist excludes the given domains, and any of their
+// subdomains, from the crawl.
+func WithDomainBlacklist(domains []string) Option {
+	return func(c *Crawler) {
+		c.domainBlacklist = domains
+	}
+}
+
+// WithURLPrefix restricts the crawl to URLs whose path starts with
+// prefix, scoping it to a section of a site.
+func WithURLPrefix(prefix string) Option {
+	return func(c *Crawler) {
+		c.urlPrefix = prefix
+	}
+}
+
+// WithSearch enables content extraction alongside the mirror: for
+// every fetched page of interest per cfg, the images and CSS-selector
+// matches found on it are appended to outputFile as JSON lines,
+// independent of the mirrored copy written to storage. Disabled by
+// default.
+func WithSearch(cfg search.Config, outputFile string) Option {
+	return func(c *Crawler) {
+		c.searchConfig = &cfg
+		c.searchOutputFile = outputFile
+	}
+}
+
+// WithSeeders runs every given Seeder before the crawl's link-following
+// traversal begins, feeding the URLs they discover into the frontier
+// alongside the start URL, same as WithSitemap does for sitemaps and
+// feeds. Disabled by default.
+func WithSeeders(seeders ...Seeder) Option {
+	return func(c *Crawler) {
+		c.seeders = seeders
+	}
+}
+
+// WithRPSPerHost caps the number of requests made to any single host
+// per second via a token-bucket, independent of both the worker pool
+// size (WithConcurrency) and any Crawl-delay/MinDelay wait. Defaults to
+// unlimited.
+func WithRPSPerHost(rps float64) Option {
+	return func(c *Crawler) {
+		c.rpsPerHost = rps
+	}
+}
+
+// WithMaxBandwidth caps total outbound bandwidth, across every host and
+// worker, to maxBytesPerSecond bytes per second, counted against each
+// response body once it has been read in full. A single response larger than
+// maxBytesPerSecond is logged and fetched without being throttled,
+// since the token bucket can never hold enough tokens to cover it.
+// Defaults to unlimited.
+func WithMaxBandwidth(maxBytesPerSecond int) Option {
+	return func(c *Crawler) {
+		c.maxBandwidth = maxBytesPerSecond
+	}
+}
+
+// WithFetcher replaces the crawler's default conditional net/http GET
+// with fetcher for every url in the crawl, e.g. a ChromeFetcher to
+// render JavaScript-built pages. This applies to the whole crawl rather
+// than per-url. fetcher is still handed each url's cached
+// ETag/Last-Modified; one that ignores them, like ChromeFetcher, never
+// reports a 304, so with it -revalidate's 304 shortcut is unavailable
+// and every page is re-fetched in full on every run. Defaults to nil,
+// using an HTTPFetcher built on getUrl.
+func WithFetcher(fetcher Fetcher) Option {
+	return func(c *Crawler) {
+		c.fetcher = fetcher
+	}
+}
+
+// WithPipeline replaces the Pipeline Run drives each frontier item
+// through. Defaults to NewDefaultPipeline(c), which reproduces the
+// fetch-then-follow behavior Run has always had; pass a Pipeline built
+// with NewPipeline and Use/InsertBefore/InsertAfter/Replace to add,
+// reorder, or substitute stages instead.
+func WithPipeline(pipeline *Pipeline) Option {
+	return func(c *Crawler) {
+		c.pipeline = pipeline
+	}
 }
 
 // New creates a new Crawler.
-func New(startUrl string, destDir string, timeout time.Duration, log *log.Logger) *Crawler {
-	return &Crawler{
+func New(startUrl string, destDir string, timeout time.Duration, log *log.Logger, opts ...Option) *Crawler {
+	c := &Crawler{
 		startUrl: startUrl,
 		destDir:  destDir,
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
 		log:         log,
-		visitedUrls: make(map[string]bool),
-	}
-}
-
-// Run runs the crawler.
-// All urls are fetched concurrently.
-func (c *Crawler) Run() error {
-	c.visitedUrls[c.startUrl] = true
-	urls := []string{c.startUrl}
-	for len(urls) > 0 {
-		var wg sync.WaitGroup
-		nextUrls := []string{}
-		for _, url := range urls {
-			wg.Add(1)
-			go func(url string) {
-				defer wg.Done()
-				doc, err := processUrl(c.httpClient, c.destDir, url, c.log)
-				if err != nil {
-					c.log.Println(err)
-					return
+		userAgent:   defaultUserAgent,
+		maxDepth:    unlimitedDepth,
+		maxPages:    unlimitedPages,
+		concurrency: defaultConcurrency,
+		resume:      true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.storage == nil {
+		c.storage = storage.NewFileStorage(destDir)
+	}
+	if c.proxyURL != "" || c.insecureSkipVerify {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if c.proxyURL != "" {
+			if parsed, err := neturl.Parse(c.proxyURL); err == nil {
+				transport.Proxy = http.ProxyURL(parsed)
+			} else {
+				log.Printf("crawler: invalid proxy url %q: %v, ignoring\n", c.proxyURL, err)
+			}
+		}
+		if c.insecureSkipVerify {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		c.httpClient.Transport = transport
+	}
+	c.politeness = robots.NewPolicy(c.userAgent, c.minDelay, c.httpClient)
+	if c.rpsPerHost > 0 {
+		c.hostLimiter = newHostLimiter(c.rpsPerHost)
+	}
+	if c.maxBandwidth > 0 {
+		c.bandwidthLimiter = rate.NewLimiter(rate.Limit(c.maxBandwidth), c.maxBandwidth)
+	}
+	var filters []frontier.Filter
+	if c.includeRegexp != nil {
+		filters = append(filters, frontier.IncludeRegexp(c.includeRegexp))
+	}
+	if c.excludeRegexp != nil {
+		filters = append(filters, frontier.ExcludeRegexp(c.excludeRegexp))
+	}
+	if len(c.allowedDomains) > 0 {
+		filters = append(filters, frontier.AllowedDomains(c.allowedDomains, c.includeSubdomains))
+	}
+	if len(c.domainBlacklist) > 0 {
+		filters = append(filters, frontier.DomainBlacklist(c.domainBlacklist))
+	}
+	if c.urlPrefix != "" {
+		filters = append(filters, frontier.PathPrefix(c.urlPrefix))
+	}
+	c.frontier = frontier.New(c.maxDepth, c.maxPages, filters...)
+	if c.rewriteAssets {
+		c.assetRewriter = assets.NewRewriter(c.storage, c.assetConcurrency)
+	}
+	return c
+}
+
+// Close releases any resources held by the crawler's storage backend.
+// It should be called once Run has returned.
+func (c *Crawler) Close() error {
+	return c.storage.Close()
+}
+
+// PipelineStats returns the processed/error/latency stats of the
+// Pipeline Run drove items through, keyed by stage name. It returns nil
+// if Run has not been called yet and no Pipeline was given via
+// WithPipeline.
+func (c *Crawler) PipelineStats() map[string]StageStats {
+	if c.pipeline == nil {
+		return nil
+	}
+	return c.pipeline.Stats()
+}
+
+// visit fetches a single item and returns the items discovered by
+// following its links, if any.
+func (c *Crawler) visit(ctx context.Context, item frontier.Item) []frontier.Item {
+	doc, err := processUrl(ctx, c.httpClient, c.storage, c.checkpoint, c.assetRewriter, item.URL, c.politeness, c.hostLimiter, c.bandwidthLimiter, c.fetcher, c.userAgent, c.log)
+	if err != nil {
+		c.log.Println(err)
+		return nil
+	}
+	if doc == nil {
+		return nil
+	}
+	if c.searchRecorder != nil {
+		if match, ok := search.Extract(*c.searchConfig, item.URL, doc); ok {
+			if err := c.searchRecorder.Record(match); err != nil {
+				c.log.Println(err)
+			}
+		}
+	}
+	if c.useSitemap && c.sitemapOnly {
+		return nil
+	}
+	return getNextUrls(c, doc, item.Depth)
+}
+
+// Run runs the crawler, fetching up to Concurrency pages at a time,
+// until the frontier is exhausted or ctx is done. If the crawler was
+// built with WithCheckpoint, its checkpoint is loaded and kept up to
+// date as the crawl progresses; unless WithResume(false) was given, Run
+// also resumes from where a previous, interrupted Run left off instead
+// of starting over: URLs already fetched successfully are skipped (or,
+// with WithRevalidate, conditionally re-checked), and any items still
+// queued when a previous Run stopped are picked back up first. If built
+// with WithSitemap, the sitemap- and feed-discovered URLs are added to
+// the seed list alongside the start URL, as are any URLs discovered by
+// WithSeeders' Seeders. If built with WithSearch, the recorder it
+// writes to is opened here and closed when Run returns. Each item is
+// driven through c's Pipeline (NewDefaultPipeline(c), unless
+// WithPipeline overrode it); PipelineStats reports what it did after
+// Run returns.
+func (c *Crawler) Run(ctx context.Context) error {
+	concurrency := c.concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	if c.searchConfig != nil {
+		rec, err := search.NewRecorder(c.searchOutputFile)
+		if err != nil {
+			return err
+		}
+		c.searchRecorder = rec
+		defer rec.Close()
+	}
+
+	seed := []frontier.Item{{URL: c.startUrl, Depth: 0}}
+	for _, url := range c.extraSeeds {
+		if c.frontier.Add(url, 0) {
+			seed = append(seed, frontier.Item{URL: url, Depth: 0})
+		}
+	}
+	if c.checkpointPath != "" {
+		cp, err := checkpoint.Load(c.checkpointPath)
+		if err != nil {
+			return err
+		}
+		c.checkpoint = cp
+		if c.resume {
+			if !c.revalidate {
+				for _, url := range cp.URLs() {
+					if cp.Done(url) {
+						c.frontier.Add(url, 0)
+					}
+				}
+			}
+			for _, item := range cp.Pending() {
+				if c.frontier.Add(item.URL, item.Depth) {
+					seed = append(seed, item)
 				}
-				nextUrls = append(nextUrls, getNextUrls(c, doc)...)
-			}(url)
+			}
+		}
+	}
+
+	if c.useSitemap {
+		discovered, err := discoverSeeds(ctx, c.httpClient, c.userAgent, c.startUrl, c.since)
+		if err != nil {
+			return err
+		}
+		for _, url := range discovered {
+			if c.frontier.Add(url, 0) {
+				seed = append(seed, frontier.Item{URL: url, Depth: 0})
+			}
+		}
+	}
+
+	for _, seeder := range c.seeders {
+		discovered, err := seeder.Seed(ctx, c.httpClient, c.userAgent, c.startUrl)
+		if err != nil {
+			return err
+		}
+		for _, url := range discovered {
+			if c.frontier.Add(url, 0) {
+				seed = append(seed, frontier.Item{URL: url, Depth: 0})
+			}
+		}
+	}
+
+	if c.pipeline == nil {
+		c.pipeline = NewDefaultPipeline(c)
+	}
+
+	tokens := make(chan struct{}, concurrency)
+	worklist := make(chan []frontier.Item)
+	pending := 0
+
+	spawn := func(item frontier.Item) {
+		pending++
+		go func(item frontier.Item) {
+			select {
+			case tokens <- struct{}{}:
+				defer func() { <-tokens }()
+			case <-ctx.Done():
+				worklist <- []frontier.Item{item}
+				return
+			}
+			job, err := c.pipeline.Process(ctx, &Job{Item: item})
+			var children []frontier.Item
+			if err != nil {
+				c.log.Println(err)
+			} else if job != nil {
+				children = job.Children
+			}
+			if ctx.Err() != nil {
+				// item may not have been fetched (or saved to the
+				// checkpoint) before ctx was cancelled mid-visit;
+				// requeue it alongside any children so a resumed
+				// Run picks it back up instead of losing it.
+				children = append(children, item)
+			}
+			select {
+			case worklist <- children:
+			case <-ctx.Done():
+				worklist <- children
+			}
+		}(item)
+	}
+
+	for _, item := range seed {
+		c.frontier.Add(item.URL, item.Depth)
+		if c.checkpoint != nil && c.resume && !c.revalidate && c.checkpoint.Done(item.URL) {
+			continue
+		}
+		spawn(item)
+	}
+
+	var runErr error
+	var stillQueued []frontier.Item
+	for pending > 0 {
+		items := <-worklist
+		pending--
+		select {
+		case <-ctx.Done():
+			runErr = ctx.Err()
+			stillQueued = append(stillQueued, items...)
+		default:
+			for _, item := range items {
+				spawn(item)
+			}
+		}
+	}
+
+	if c.checkpoint != nil {
+		c.checkpoint.SetPending(stillQueued)
+		if err := c.checkpoint.Flush(); err != nil {
+			if runErr == nil {
+				runErr = err
+			}
 		}
-		wg.Wait()
-		urls = nextUrls
 	}
-	return nil
+	return runErr
 }
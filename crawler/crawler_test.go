@@ -4,36 +4,82 @@
 package crawler
 
 import (
-	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
-	"io/fs"
 	"log"
 	"net/http"
-	goUrl "net/url"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/stretchr/testify/require"
+	"github.com/tcmsoftware/mirroring-web-crawler/assets"
+	"github.com/tcmsoftware/mirroring-web-crawler/checkpoint"
 	"github.com/tcmsoftware/mirroring-web-crawler/fixtures"
+	"github.com/tcmsoftware/mirroring-web-crawler/frontier"
+	"github.com/tcmsoftware/mirroring-web-crawler/robots"
+	"github.com/tcmsoftware/mirroring-web-crawler/storage"
+	"golang.org/x/time/rate"
 )
 
+// fakeStorage is an in-memory storage.Storage used across crawler
+// tests so they don't touch the filesystem. It is safe for concurrent
+// use so it can back tests that exercise Run's worker pool.
+type fakeStorage struct {
+	mu        sync.Mutex
+	existing  map[string]bool
+	putErr    error
+	putCalled bool
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{existing: make(map[string]bool)}
+}
+
+func (s *fakeStorage) Exists(url string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.existing[url]
+}
+
+func (s *fakeStorage) Put(url string, statusCode int, headers http.Header, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putCalled = true
+	if s.putErr != nil {
+		return s.putErr
+	}
+	s.existing[url] = true
+	return nil
+}
+
+func (s *fakeStorage) Close() error {
+	return nil
+}
+
 func Test_getUrl(t *testing.T) {
 	testCases := []struct {
 		name          string
-		mockedGet     func(httpClient *http.Client, url string) (*http.Response, error)
+		mockedGet     func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error)
 		expectedError error
 	}{
 		{
 			name: "happy path",
-			mockedGet: func(httpClient *http.Client, url string) (*http.Response, error) {
+			mockedGet: func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
 				return new(http.Response), nil
 			},
 		},
 		{
 			name: "error",
-			mockedGet: func(httpClient *http.Client, url string) (*http.Response, error) {
+			mockedGet: func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
 				return nil, errors.New("random error")
 			},
 			expectedError: errors.New("making get request to some url: random error"),
@@ -43,7 +89,7 @@ func Test_getUrl(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			get = tc.mockedGet
 			c := New("someurl", "somedir", 0, nil)
-			resp, err := getUrl(c.httpClient, "some url")
+			resp, err := getUrl(context.Background(), c.httpClient, "some url", c.userAgent, "", "")
 			if err != nil {
 				checkIfErrorIsExpected(t, err, tc.expectedError)
 				require.Equal(t, tc.expectedError.Error(), err.Error())
@@ -57,17 +103,17 @@ func Test_getUrl(t *testing.T) {
 
 func Test_getNextUrls(t *testing.T) {
 	testCases := []struct {
-		name             string
-		fixture          string
-		alreadyVisited   []string
-		expectedMextUrls []string
+		name              string
+		fixture           string
+		alreadyVisited    []string
+		expectedNextItems []frontier.Item
 	}{
 		{
 			name:    "existing links are from same domain and not yet visited",
 			fixture: fixtures.AllLinksFromSameDomain,
-			expectedMextUrls: []string{
-				"someurl/some_section/2023/01/19/page1.html",
-				"someurl/some_section/2023/02/13/page2.html",
+			expectedNextItems: []frontier.Item{
+				{URL: "someurl/some_section/2023/01/19/page1.html", Depth: 1},
+				{URL: "someurl/some_section/2023/02/13/page2.html", Depth: 1},
 			},
 		},
 		{
@@ -76,22 +122,29 @@ func Test_getNextUrls(t *testing.T) {
 			alreadyVisited: []string{
 				"someurl/some_section/2023/01/19/page1.html",
 			},
-			expectedMextUrls: []string{
-				"someurl/some_section/2023/02/13/page2.html",
+			expectedNextItems: []frontier.Item{
+				{URL: "someurl/some_section/2023/02/13/page2.html", Depth: 1},
 			},
 		},
 		{
 			name:    "one link without href",
 			fixture: fixtures.OneLinkWithoutHref,
-			expectedMextUrls: []string{
-				"someurl/some_section/2023/02/13/page2.html",
+			expectedNextItems: []frontier.Item{
+				{URL: "someurl/some_section/2023/02/13/page2.html", Depth: 1},
 			},
 		},
 		{
 			name:    "mixed domains",
 			fixture: fixtures.LiksWithMixedDomains,
-			expectedMextUrls: []string{
-				"someurl/some_section/2023/02/13/page2.html",
+			expectedNextItems: []frontier.Item{
+				{URL: "someurl/some_section/2023/02/13/page2.html", Depth: 1},
+			},
+		},
+		{
+			name:    "nofollow links are skipped",
+			fixture: fixtures.OneLinkWithNofollow,
+			expectedNextItems: []frontier.Item{
+				{URL: "someurl/some_section/2023/02/13/page2.html", Depth: 1},
 			},
 		},
 	}
@@ -99,12 +152,12 @@ func Test_getNextUrls(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			c := New("someurl", "somedir", 0, nil)
 			for _, u := range tc.alreadyVisited {
-				c.visitedUrls[u] = true
+				c.frontier.Add(u, 1)
 			}
 			doc, err := fixtures.HtmlToDoc(tc.fixture)
 			require.Nil(t, err)
-			nextUrls := getNextUrls(c, doc)
-			require.Equal(t, tc.expectedMextUrls, nextUrls)
+			nextItems := getNextUrls(c, doc, 0)
+			require.Equal(t, tc.expectedNextItems, nextItems)
 		})
 	}
 }
@@ -132,10 +185,7 @@ func Test_parseResponse(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			goqueryNewDocumentFromReader = tc.mockGoqueryNewDocumentFromReader
-			resp := &http.Response{
-				Body: io.NopCloser(bytes.NewReader([]byte(""))),
-			}
-			doc, err := parseResponse("someurl", resp)
+			doc, err := parseResponse("someurl", []byte(""))
 			if err != nil {
 				checkIfErrorIsExpected(t, err, tc.expectedError)
 				require.Equal(t, tc.expectedError.Error(), err.Error())
@@ -147,204 +197,43 @@ func Test_parseResponse(t *testing.T) {
 	}
 }
 
-func Test_getPagePath(t *testing.T) {
-	testCases := []struct {
-		name          string
-		url           string
-		mockParseUrl  func(rawURL string) (*goUrl.URL, error)
-		expectedPath  string
-		expectedError error
-	}{
-		{
-			name:         "adds index.html for root path",
-			url:          "https://blog.cleancoder.com/",
-			expectedPath: "destDir/blog.cleancoder.com/index.html",
-		},
-		{
-			name:         "adds uri",
-			url:          "https://blog.cleancoder.com/uncle-bob/2019/02/01/somePage.html",
-			expectedPath: "destDir/blog.cleancoder.com/uncle-bob/2019/02/01/somePage.html",
-		},
-		{
-			name: "error",
-			url:  "https://blog.cleancoder.com/",
-			mockParseUrl: func(rawURL string) (*goUrl.URL, error) {
-				return nil, errors.New("random error")
-			},
-			expectedError: errors.New("parsing url https://blog.cleancoder.com/: random error"),
-		},
-	}
-	originalParseUrl := parseUrl
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			if tc.mockParseUrl != nil {
-				parseUrl = tc.mockParseUrl
-			} else {
-				parseUrl = originalParseUrl
-			}
-			path, err := getPagePath("destDir", tc.url)
-			if err != nil {
-				checkIfErrorIsExpected(t, err, tc.expectedError)
-				require.Equal(t, tc.expectedError.Error(), err.Error())
-			} else {
-				checkIfErrorIsNotExpected(t, err, tc.expectedError)
-				require.Equal(t, tc.expectedPath, path)
-			}
-		})
-	}
-}
-
-func Test_saveToDisk(t *testing.T) {
-	testCases := []struct {
-		name                  string
-		mockOsCreate          func(name string) (*os.File, error)
-		mockGetDocHtml        func(doc *goquery.Document) (string, error)
-		mockWriteStringToFile func(f *os.File, data string) (int, error)
-		expectedError         error
-	}{
-		{
-			name: "happy path",
-			mockOsCreate: func(name string) (*os.File, error) {
-				return new(os.File), nil
-			},
-			mockGetDocHtml: func(doc *goquery.Document) (string, error) {
-				return "something", nil
-			},
-			mockWriteStringToFile: func(f *os.File, data string) (int, error) {
-				bytesWritten := 10
-				return bytesWritten, nil
-			},
-		},
-		{
-			name: "error creating file",
-			mockOsCreate: func(name string) (*os.File, error) {
-				return nil, errors.New("random error")
-			},
-			expectedError: errors.New("creating file for someurl: random error"),
-		},
-		{
-			name: "error getting doc html",
-			mockOsCreate: func(name string) (*os.File, error) {
-				return new(os.File), nil
-			},
-			mockGetDocHtml: func(doc *goquery.Document) (string, error) {
-				return "", errors.New("random error")
-			},
-			expectedError: errors.New("converting someurl to HTML: random error"),
-		},
-		{
-			name: "error writing file",
-			mockOsCreate: func(name string) (*os.File, error) {
-				return new(os.File), nil
-			},
-			mockGetDocHtml: func(doc *goquery.Document) (string, error) {
-				return "something", nil
-			},
-			mockWriteStringToFile: func(f *os.File, data string) (int, error) {
-				return 0, errors.New("random error")
-			},
-			expectedError: errors.New("writing HTML file for someurl: random error"),
-		},
-	}
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			osCreate = tc.mockOsCreate
-			getDocHtml = tc.mockGetDocHtml
-			writeStringToFile = tc.mockWriteStringToFile
-			err := saveToDisk("someurl", "somepath", new(goquery.Document))
-			if err != nil {
-				checkIfErrorIsExpected(t, err, tc.expectedError)
-				require.Equal(t, tc.expectedError.Error(), err.Error())
-			} else {
-				checkIfErrorIsNotExpected(t, err, tc.expectedError)
-			}
-		})
-	}
-}
-
 func Test_savePage(t *testing.T) {
 	testCases := []struct {
-		name            string
-		mockGetPagePath func(destDir string, url string) (string, error)
-		mockOsStat      func(name string) (fs.FileInfo, error)
-		mockOsMkdirAll  func(path string, perm fs.FileMode) error
-		mockSaveToDisk  func(url string, pagePath string, doc *goquery.Document) error
-		expectedError   error
+		name           string
+		alreadyExists  bool
+		putErr         error
+		expectedError  error
+		expectPutCalls bool
 	}{
 		{
-			name: "happy path",
-			mockGetPagePath: func(destDir, url string) (string, error) {
-				return "path", nil
-			},
-			mockOsStat: func(name string) (fs.FileInfo, error) {
-				return nil, errors.New("random error")
-			},
-			mockOsMkdirAll: func(path string, perm fs.FileMode) error {
-				return nil
-			},
-			mockSaveToDisk: func(url, pagePath string, doc *goquery.Document) error {
-				return nil
-			},
-		},
-		{
-			name: "file already exists",
-			mockGetPagePath: func(destDir, url string) (string, error) {
-				return "path", nil
-			},
-			mockOsStat: func(name string) (fs.FileInfo, error) {
-				return nil, nil
-			},
-		},
-		{
-			name: "error getting page path",
-			mockGetPagePath: func(destDir, url string) (string, error) {
-				return "", errors.New("random error")
-			},
-			expectedError: errors.New("random error"),
+			name:           "happy path",
+			expectPutCalls: true,
 		},
 		{
-			name: "error creating dir",
-			mockGetPagePath: func(destDir, url string) (string, error) {
-				return "path", nil
-			},
-			mockOsStat: func(name string) (fs.FileInfo, error) {
-				return nil, errors.New("random error")
-			},
-			mockOsMkdirAll: func(path string, perm fs.FileMode) error {
-				return errors.New("random error")
-			},
-			expectedError: errors.New("creating directory for someurl: random error"),
+			name:          "already exists, skipped",
+			alreadyExists: true,
 		},
 		{
-			name: "error saving to disk",
-			mockGetPagePath: func(destDir, url string) (string, error) {
-				return "path", nil
-			},
-			mockOsStat: func(name string) (fs.FileInfo, error) {
-				return nil, errors.New("random error")
-			},
-			mockOsMkdirAll: func(path string, perm fs.FileMode) error {
-				return nil
-			},
-			mockSaveToDisk: func(url, pagePath string, doc *goquery.Document) error {
-				return errors.New("random error")
-			},
-			expectedError: errors.New("random error"),
+			name:           "error putting",
+			putErr:         errors.New("random error"),
+			expectedError:  errors.New("random error"),
+			expectPutCalls: true,
 		},
 	}
 	log := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			getPagePath = tc.mockGetPagePath
-			osStat = tc.mockOsStat
-			osMkdirAll = tc.mockOsMkdirAll
-			saveToDisk = tc.mockSaveToDisk
-			err := savePage("destDir", "someurl", new(goquery.Document), log)
-			if err != nil {
-				checkIfErrorIsExpected(t, err, tc.expectedError)
-				require.Equal(t, tc.expectedError.Error(), err.Error())
+			st := newFakeStorage()
+			st.putErr = tc.putErr
+			if tc.alreadyExists {
+				st.existing["someurl"] = true
+			}
+			err := savePage(st, "someurl", http.StatusOK, nil, []byte("body"), log)
+			require.Equal(t, tc.expectPutCalls, st.putCalled)
+			if tc.expectedError != nil {
+				require.EqualError(t, err, tc.expectedError.Error())
 			} else {
-				checkIfErrorIsNotExpected(t, err, tc.expectedError)
+				require.Nil(t, err)
 			}
 		})
 	}
@@ -381,49 +270,49 @@ func Test_getAbsoluteUrl(t *testing.T) {
 func Test_processUrl(t *testing.T) {
 	testCases := []struct {
 		name              string
-		mockGetUrl        func(httpClient *http.Client, url string) (*http.Response, error)
-		mockParseResponse func(url string, resp *http.Response) (*goquery.Document, error)
-		mockSavePage      func(destDir string, url string, doc *goquery.Document, log *log.Logger) error
+		mockGetUrl        func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error)
+		mockParseResponse func(url string, body []byte) (*goquery.Document, error)
+		mockSavePage      func(st storage.Storage, url string, statusCode int, headers http.Header, body []byte, log *log.Logger) error
 		expectedError     error
 	}{
 		{
 			name: "happy path",
-			mockGetUrl: func(httpClient *http.Client, url string) (*http.Response, error) {
-				return new(http.Response), nil
+			mockGetUrl: func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
 			},
-			mockParseResponse: func(url string, resp *http.Response) (*goquery.Document, error) {
+			mockParseResponse: func(url string, body []byte) (*goquery.Document, error) {
 				return new(goquery.Document), nil
 			},
-			mockSavePage: func(destDir, url string, doc *goquery.Document, log *log.Logger) error {
+			mockSavePage: func(st storage.Storage, url string, statusCode int, headers http.Header, body []byte, log *log.Logger) error {
 				return nil
 			},
 		},
 		{
 			name: "error getting url",
-			mockGetUrl: func(httpClient *http.Client, url string) (*http.Response, error) {
+			mockGetUrl: func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
 				return nil, errors.New("random error")
 			},
 			expectedError: errors.New("random error"),
 		},
 		{
 			name: "error parsing response",
-			mockGetUrl: func(httpClient *http.Client, url string) (*http.Response, error) {
-				return new(http.Response), nil
+			mockGetUrl: func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
 			},
-			mockParseResponse: func(url string, resp *http.Response) (*goquery.Document, error) {
+			mockParseResponse: func(url string, body []byte) (*goquery.Document, error) {
 				return nil, errors.New("random error")
 			},
 			expectedError: errors.New("random error"),
 		},
 		{
 			name: "error saving page",
-			mockGetUrl: func(httpClient *http.Client, url string) (*http.Response, error) {
-				return new(http.Response), nil
+			mockGetUrl: func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
 			},
-			mockParseResponse: func(url string, resp *http.Response) (*goquery.Document, error) {
+			mockParseResponse: func(url string, body []byte) (*goquery.Document, error) {
 				return new(goquery.Document), nil
 			},
-			mockSavePage: func(destDir, url string, doc *goquery.Document, log *log.Logger) error {
+			mockSavePage: func(st storage.Storage, url string, statusCode int, headers http.Header, body []byte, log *log.Logger) error {
 				return errors.New("random error")
 			},
 			expectedError: errors.New("random error"),
@@ -435,7 +324,7 @@ func Test_processUrl(t *testing.T) {
 			getUrl = tc.mockGetUrl
 			parseResponse = tc.mockParseResponse
 			savePage = tc.mockSavePage
-			doc, err := processUrl(new(http.Client), "destDir", "someurl", log)
+			doc, err := processUrl(context.Background(), new(http.Client), newFakeStorage(), nil, nil, "someurl", nil, nil, nil, nil, "someagent", log)
 			if err != nil {
 				checkIfErrorIsExpected(t, err, tc.expectedError)
 				require.Equal(t, tc.expectedError.Error(), err.Error())
@@ -447,43 +336,371 @@ func Test_processUrl(t *testing.T) {
 	}
 }
 
+func Test_processUrl_threadsRealStatusCode(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	originalGetUrl := getUrl
+	originalParseResponse := parseResponse
+	originalSavePage := savePage
+	defer func() {
+		getUrl = originalGetUrl
+		parseResponse = originalParseResponse
+		savePage = originalSavePage
+	}()
+
+	getUrl = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNotFound, Header: make(http.Header), Body: io.NopCloser(strings.NewReader("not found"))}, nil
+	}
+	parseResponse = func(url string, body []byte) (*goquery.Document, error) {
+		return new(goquery.Document), nil
+	}
+	var gotStatusCode int
+	savePage = func(st storage.Storage, url string, statusCode int, headers http.Header, body []byte, log *log.Logger) error {
+		gotStatusCode = statusCode
+		return nil
+	}
+
+	_, err := processUrl(context.Background(), new(http.Client), newFakeStorage(), nil, nil, "someurl", nil, nil, nil, nil, "someagent", logger)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusNotFound, gotStatusCode)
+}
+
+func Test_processUrl_disallowedByRobots(t *testing.T) {
+	log := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	called := false
+	getUrl = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		called = true
+		return new(http.Response), nil
+	}
+	policy := robots.NewPolicy("someagent", 0, new(http.Client))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /\n")
+	}))
+	defer server.Close()
+	doc, err := processUrl(context.Background(), new(http.Client), newFakeStorage(), nil, nil, server.URL+"/page.html", policy, nil, nil, nil, "someagent", log)
+	require.Nil(t, err)
+	require.Nil(t, doc)
+	require.False(t, called)
+}
+
+func Test_processUrl_notModified(t *testing.T) {
+	log := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	getUrl = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		require.Equal(t, `"abc"`, etag)
+		require.Equal(t, "Wed, 21 Oct 2015 07:28:00 GMT", lastModified)
+		return &http.Response{StatusCode: http.StatusNotModified, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	cp, err := checkpoint.Load(filepath.Join(t.TempDir(), "visited.json"))
+	require.NoError(t, err)
+	cp.Set("someurl", checkpoint.Entry{Status: checkpoint.StatusOK, ETag: `"abc"`, LastModified: "Wed, 21 Oct 2015 07:28:00 GMT"})
+	st := newFakeStorage()
+	doc, err := processUrl(context.Background(), new(http.Client), st, cp, nil, "someurl", nil, nil, nil, nil, "someagent", log)
+	require.NoError(t, err)
+	require.Nil(t, doc)
+	require.False(t, st.putCalled)
+}
+
+func Test_processUrl_withAssetRewriter(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logo.png" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	pageUrl := server.URL + "/page.html"
+	getUrl = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		body := `<html><body><img src="/logo.png"></body></html>`
+		return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+	parseResponse = func(url string, body []byte) (*goquery.Document, error) {
+		return goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	}
+	st := newFakeStorage()
+	var savedBody []byte
+	savePage = func(st storage.Storage, url string, statusCode int, headers http.Header, body []byte, log *log.Logger) error {
+		savedBody = body
+		return nil
+	}
+	rewriter := assets.NewRewriter(st, 1)
+	doc, err := processUrl(context.Background(), server.Client(), st, nil, rewriter, pageUrl, nil, nil, nil, nil, "someagent", logger)
+	require.NoError(t, err)
+	require.NotNil(t, doc)
+	require.Contains(t, string(savedBody), `src="logo.png"`)
+}
+
 func TestRun(t *testing.T) {
 	testCases := []struct {
 		name            string
-		mockProcessUrl  func(httpClient *http.Client, destDir string, url string, log *log.Logger) (*goquery.Document, error)
-		mockGetNextUrls func(c *Crawler, doc *goquery.Document) []string
+		mockProcessUrl  func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error)
+		mockGetNextUrls func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item
 	}{
 		{
 			name: "happy path",
-			mockProcessUrl: func(httpClient *http.Client, destDir, url string, log *log.Logger) (*goquery.Document, error) {
+			mockProcessUrl: func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
 				return new(goquery.Document), nil
 			},
-			mockGetNextUrls: func(c *Crawler, doc *goquery.Document) []string {
-				return []string{}
+			mockGetNextUrls: func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+				return []frontier.Item{}
 			},
 		},
 		{
 			name: "error processing url",
-			mockProcessUrl: func(httpClient *http.Client, destDir, url string, log *log.Logger) (*goquery.Document, error) {
+			mockProcessUrl: func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
 				return nil, errors.New("random error")
 			},
-			mockGetNextUrls: func(c *Crawler, doc *goquery.Document) []string {
-				return []string{}
+			mockGetNextUrls: func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+				return []frontier.Item{}
 			},
 		},
 	}
 	log := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	originalProcessUrl := processUrl
+	originalGetNextUrls := getNextUrls
+	defer func() {
+		processUrl = originalProcessUrl
+		getNextUrls = originalGetNextUrls
+	}()
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			processUrl = tc.mockProcessUrl
 			getNextUrls = tc.mockGetNextUrls
 			c := New("firsturl", "somedir", 0, log)
-			err := c.Run()
+			err := c.Run(context.Background())
 			require.Nil(t, err)
 		})
 	}
 }
 
+func Test_Run_CancellationAndResume(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	const startUrl = "http://first.example.com"
+	const childUrl = "http://first.example.com/child.html"
+
+	originalProcessUrl := processUrl
+	originalGetNextUrls := getNextUrls
+	defer func() {
+		processUrl = originalProcessUrl
+		getNextUrls = originalGetNextUrls
+	}()
+
+	useMocks := func(onVisit func(url string)) {
+		processUrl = func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
+			st.Put(url, http.StatusOK, nil, nil)
+			if cp != nil {
+				cp.Set(url, checkpoint.Entry{Status: checkpoint.StatusOK})
+			}
+			if onVisit != nil {
+				onVisit(url)
+			}
+			return new(goquery.Document), nil
+		}
+		getNextUrls = func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+			if depth == 0 && c.frontier.Add(childUrl, 1) {
+				return []frontier.Item{{URL: childUrl, Depth: 1}}
+			}
+			return nil
+		}
+	}
+
+	// Baseline: an uninterrupted run visits both pages.
+	useMocks(nil)
+	stFull := newFakeStorage()
+	cFull := New(startUrl, "somedir", 0, logger, WithStorage(stFull))
+	require.Nil(t, cFull.Run(context.Background()))
+	expected := map[string]bool{startUrl: true, childUrl: true}
+	require.Equal(t, expected, stFull.existing)
+
+	// An interrupted run only gets through the start url, and
+	// checkpoints the child it discovered but never visited.
+	checkpointPath := filepath.Join(t.TempDir(), "visited.json")
+	stResume := newFakeStorage()
+	ctx, cancel := context.WithCancel(context.Background())
+	useMocks(func(url string) {
+		if url == startUrl {
+			cancel()
+		}
+	})
+	c1 := New(startUrl, "somedir", 0, logger, WithStorage(stResume), WithCheckpoint(checkpointPath), WithConcurrency(1))
+	err := c1.Run(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, map[string]bool{startUrl: true}, stResume.existing)
+
+	// Resuming picks up the child left in the checkpoint and finishes
+	// with the same final tree as the uninterrupted run.
+	useMocks(nil)
+	c2 := New(startUrl, "somedir", 0, logger, WithStorage(stResume), WithCheckpoint(checkpointPath), WithConcurrency(1))
+	require.Nil(t, c2.Run(context.Background()))
+	require.Equal(t, expected, stResume.existing)
+}
+
+// Test_Run_CancellationMidVisit_PreservesItem covers cancellation while
+// an item's visit is already underway (it has acquired a worker token),
+// as opposed to Test_Run_CancellationAndResume, which only cancels
+// between items. The in-flight item must still end up in the
+// checkpoint's pending list so a resumed Run does not lose it.
+func Test_Run_CancellationMidVisit_PreservesItem(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	const startUrl = "http://first.example.com"
+
+	originalProcessUrl := processUrl
+	defer func() { processUrl = originalProcessUrl }()
+
+	entered := make(chan struct{})
+	processUrl = func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
+		close(entered)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	checkpointPath := filepath.Join(t.TempDir(), "visited.json")
+	st := newFakeStorage()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := New(startUrl, "somedir", 0, logger, WithStorage(st), WithCheckpoint(checkpointPath), WithConcurrency(1))
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- c.Run(ctx) }()
+	<-entered
+	cancel()
+	require.ErrorIs(t, <-runErr, context.Canceled)
+
+	cp, err := checkpoint.Load(checkpointPath)
+	require.NoError(t, err)
+	var pendingUrls []string
+	for _, item := range cp.Pending() {
+		pendingUrls = append(pendingUrls, item.URL)
+	}
+	require.Contains(t, pendingUrls, startUrl)
+}
+
+// Test_Run_CheckpointResume_KeepsExtraSeeds covers resuming from a
+// checkpoint with pending items while WithSeeds is also configured: the
+// extra seeds must still be visited alongside whatever was pending,
+// rather than being dropped by the resume logic.
+func Test_Run_CheckpointResume_KeepsExtraSeeds(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	const startUrl = "http://first.example.com"
+	const pendingUrl = "http://first.example.com/pending.html"
+	const extraSeedUrl = "http://second.example.com"
+
+	originalProcessUrl := processUrl
+	originalGetNextUrls := getNextUrls
+	defer func() {
+		processUrl = originalProcessUrl
+		getNextUrls = originalGetNextUrls
+	}()
+
+	var mu sync.Mutex
+	var visited []string
+	processUrl = func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
+		mu.Lock()
+		visited = append(visited, url)
+		mu.Unlock()
+		return new(goquery.Document), nil
+	}
+	getNextUrls = func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+		return nil
+	}
+
+	checkpointPath := filepath.Join(t.TempDir(), "visited.json")
+	cp, err := checkpoint.Load(checkpointPath)
+	require.NoError(t, err)
+	cp.SetPending([]frontier.Item{{URL: pendingUrl, Depth: 0}})
+	require.NoError(t, cp.Flush())
+
+	c := New(startUrl, "somedir", 0, logger, WithStorage(newFakeStorage()), WithCheckpoint(checkpointPath), WithSeeds([]string{extraSeedUrl}))
+	require.NoError(t, c.Run(context.Background()))
+
+	require.ElementsMatch(t, []string{startUrl, extraSeedUrl, pendingUrl}, visited)
+}
+
+func Test_Run_Sitemap(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	const startUrl = "http://first.example.com"
+	const seededUrl = "http://first.example.com/seeded.html"
+
+	originalDiscoverSeeds := discoverSeeds
+	originalProcessUrl := processUrl
+	originalGetNextUrls := getNextUrls
+	defer func() {
+		discoverSeeds = originalDiscoverSeeds
+		processUrl = originalProcessUrl
+		getNextUrls = originalGetNextUrls
+	}()
+
+	var visited []string
+	discoverSeeds = func(ctx context.Context, httpClient *http.Client, userAgent, url string, since time.Time) ([]string, error) {
+		return []string{seededUrl}, nil
+	}
+	processUrl = func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
+		visited = append(visited, url)
+		return new(goquery.Document), nil
+	}
+	getNextUrlsCalled := false
+	getNextUrls = func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+		getNextUrlsCalled = true
+		return nil
+	}
+
+	c := New(startUrl, "somedir", 0, logger, WithSitemap(true), WithSitemapOnly(true))
+	require.Nil(t, c.Run(context.Background()))
+	require.ElementsMatch(t, []string{startUrl, seededUrl}, visited)
+	require.False(t, getNextUrlsCalled)
+}
+
+func Test_Run_RPSPerHost(t *testing.T) {
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	const startUrl = "http://example.com/a"
+
+	originalGetUrl := getUrl
+	originalParseResponse := parseResponse
+	originalSavePage := savePage
+	originalGetNextUrls := getNextUrls
+	defer func() {
+		getUrl = originalGetUrl
+		parseResponse = originalParseResponse
+		savePage = originalSavePage
+		getNextUrls = originalGetNextUrls
+	}()
+
+	var mu sync.Mutex
+	var fetchedAt []time.Time
+	getUrl = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		mu.Lock()
+		fetchedAt = append(fetchedAt, time.Now())
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	parseResponse = func(url string, body []byte) (*goquery.Document, error) {
+		return new(goquery.Document), nil
+	}
+	savePage = func(st storage.Storage, url string, statusCode int, headers http.Header, body []byte, log *log.Logger) error {
+		return nil
+	}
+	getNextUrls = func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+		return nil
+	}
+
+	const rps = 10.0
+	c := New(startUrl, "somedir", 0, logger,
+		WithStorage(newFakeStorage()),
+		WithSeeds([]string{"http://example.com/b", "http://example.com/c"}),
+		WithConcurrency(3),
+		WithRPSPerHost(rps),
+	)
+	require.Nil(t, c.Run(context.Background()))
+	require.Len(t, fetchedAt, 3)
+
+	sort.Slice(fetchedAt, func(i, j int) bool { return fetchedAt[i].Before(fetchedAt[j]) })
+	minInterval := time.Duration(float64(time.Second) / rps)
+	for i := 1; i < len(fetchedAt); i++ {
+		gap := fetchedAt[i].Sub(fetchedAt[i-1])
+		require.GreaterOrEqual(t, gap, minInterval-20*time.Millisecond)
+	}
+}
+
 func checkIfErrorIsExpected(t *testing.T, err, expectedError error) {
 	if expectedError == nil {
 		t.Fatalf(`expected no error, got "%v"`, err)
@@ -0,0 +1,56 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Fetcher retrieves the rendered content of a single url. etag and
+// lastModified, if non-empty, are the values processUrl has cached for
+// url and may be sent as conditional request headers; an implementation
+// that has no equivalent (e.g. one backed by a browser instead of a
+// plain HTTP client) is free to ignore them and always return the full
+// body. A 304 response is reported as statusCode ==
+// http.StatusNotModified with a nil body, the same as any other status;
+// processUrl treats that specially and the rest are just persisted.
+type Fetcher interface {
+	// Fetch retrieves url and returns its status code, body, the url
+	// actually served (which may differ from url after redirects), and
+	// any response headers available.
+	Fetch(ctx context.Context, url, etag, lastModified string) (statusCode int, body []byte, finalURL string, headers http.Header, err error)
+}
+
+// HTTPFetcher is the default Fetcher, issuing a conditional GET via the
+// crawler's own http.Client -- the same request processUrl has always
+// made, just behind the Fetcher interface so it can be swapped out with
+// WithFetcher.
+type HTTPFetcher struct {
+	HTTPClient *http.Client
+	UserAgent  string
+}
+
+// Fetch implements Fetcher.
+func (f *HTTPFetcher) Fetch(ctx context.Context, url, etag, lastModified string) (int, []byte, string, http.Header, error) {
+	response, err := getUrl(ctx, f.HTTPClient, url, f.UserAgent, etag, lastModified)
+	if err != nil {
+		return 0, nil, "", nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotModified {
+		return response.StatusCode, nil, url, response.Header, nil
+	}
+	body, err := readAll(response.Body)
+	if err != nil {
+		return 0, nil, "", nil, errors.Wrapf(err, "reading response body for %v", url)
+	}
+	finalURL := url
+	if response.Request != nil && response.Request.URL != nil {
+		finalURL = response.Request.URL.String()
+	}
+	return response.StatusCode, body, finalURL, response.Header, nil
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// restoreRealGetUrl undoes any stub that an earlier test in this package
+// left on the getUrl package var, since HTTPFetcher.Fetch relies on the
+// real implementation making an actual HTTP request.
+func restoreRealGetUrl(t *testing.T) {
+	t.Helper()
+	originalGetUrl, originalGet := getUrl, get
+	get = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+		return httpClient.Do(req)
+	}
+	getUrl = func(ctx context.Context, httpClient *http.Client, url, userAgent, etag, lastModified string) (*http.Response, error) {
+		resp, err := get(ctx, httpClient, url, userAgent, etag, lastModified)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+	t.Cleanup(func() { getUrl, get = originalGetUrl, originalGet })
+}
+
+func Test_HTTPFetcher_Fetch(t *testing.T) {
+	restoreRealGetUrl(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "yes")
+		w.Write([]byte("<html><body>hello</body></html>"))
+	}))
+	defer server.Close()
+
+	f := &HTTPFetcher{HTTPClient: server.Client(), UserAgent: "someagent"}
+	statusCode, body, finalURL, headers, err := f.Fetch(context.Background(), server.URL+"/page.html", "", "")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Contains(t, string(body), "hello")
+	require.Equal(t, server.URL+"/page.html", finalURL)
+	require.Equal(t, "yes", headers.Get("X-Test"))
+}
+
+func Test_HTTPFetcher_Fetch_NotModified(t *testing.T) {
+	restoreRealGetUrl(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("<html><body>hello</body></html>"))
+	}))
+	defer server.Close()
+
+	f := &HTTPFetcher{HTTPClient: server.Client(), UserAgent: "someagent"}
+	statusCode, body, _, _, err := f.Fetch(context.Background(), server.URL+"/page.html", `"v1"`, "")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotModified, statusCode)
+	require.Nil(t, body)
+}
+
+func Test_HTTPFetcher_Fetch_Error(t *testing.T) {
+	restoreRealGetUrl(t)
+	f := &HTTPFetcher{HTTPClient: new(http.Client), UserAgent: "someagent"}
+	_, _, _, _, err := f.Fetch(context.Background(), "http://127.0.0.1:0", "", "")
+	require.Error(t, err)
+}
+
+// ChromeFetcher drives an actual headless Chrome/Chromium process via
+// chromedp, which this environment does not have installed, so only its
+// field defaults are covered here; a real Fetch call needs a real
+// browser binary and belongs in an environment that has one.
+func Test_ChromeFetcher_DefaultTimeout(t *testing.T) {
+	f := &ChromeFetcher{}
+	require.Zero(t, f.Timeout)
+	require.Empty(t, f.WaitSelector)
+}
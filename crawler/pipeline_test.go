@@ -0,0 +1,172 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/require"
+	"github.com/tcmsoftware/mirroring-web-crawler/assets"
+	"github.com/tcmsoftware/mirroring-web-crawler/checkpoint"
+	"github.com/tcmsoftware/mirroring-web-crawler/frontier"
+	"github.com/tcmsoftware/mirroring-web-crawler/robots"
+	"github.com/tcmsoftware/mirroring-web-crawler/storage"
+	"golang.org/x/time/rate"
+)
+
+func Test_Pipeline_Process_RunsStagesInOrder(t *testing.T) {
+	var order []string
+	p := NewPipeline()
+	p.Use("first", PipeFunc(func(ctx context.Context, job *Job) (*Job, error) {
+		order = append(order, "first")
+		return job, nil
+	}))
+	p.Use("second", PipeFunc(func(ctx context.Context, job *Job) (*Job, error) {
+		order = append(order, "second")
+		job.Children = []frontier.Item{{URL: "http://example.com/child", Depth: 1}}
+		return job, nil
+	}))
+
+	result, err := p.Process(context.Background(), &Job{Item: frontier.Item{URL: "http://example.com"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "second"}, order)
+	require.Equal(t, []frontier.Item{{URL: "http://example.com/child", Depth: 1}}, result.Children)
+
+	stats := p.Stats()
+	require.Equal(t, 1, stats["first"].Processed)
+	require.Equal(t, 1, stats["second"].Processed)
+}
+
+func Test_Pipeline_Process_StageDropsJob(t *testing.T) {
+	p := NewPipeline()
+	p.Use("drop", PipeFunc(func(ctx context.Context, job *Job) (*Job, error) {
+		return nil, nil
+	}))
+	p.Use("never", PipeFunc(func(ctx context.Context, job *Job) (*Job, error) {
+		t.Fatal("stage after a drop must not run")
+		return job, nil
+	}))
+
+	result, err := p.Process(context.Background(), &Job{Item: frontier.Item{URL: "http://example.com"}})
+	require.NoError(t, err)
+	require.Nil(t, result)
+}
+
+func Test_Pipeline_Process_StageError(t *testing.T) {
+	wantErr := errors.New("boom")
+	p := NewPipeline()
+	p.Use("fails", PipeFunc(func(ctx context.Context, job *Job) (*Job, error) {
+		return nil, wantErr
+	}))
+
+	result, err := p.Process(context.Background(), &Job{Item: frontier.Item{URL: "http://example.com"}})
+	require.Equal(t, wantErr, err)
+	require.Nil(t, result)
+
+	stats := p.Stats()
+	require.Equal(t, 1, stats["fails"].Errors)
+}
+
+func Test_Pipeline_InsertBeforeAfterReplaceRemove(t *testing.T) {
+	p := NewPipeline()
+	noop := PipeFunc(func(ctx context.Context, job *Job) (*Job, error) { return job, nil })
+	p.Use("a", noop).Use("c", noop)
+	p.InsertBefore("c", "b", noop)
+	require.Equal(t, []string{"a", "b", "c"}, stageNames(p))
+
+	p.InsertAfter("a", "a2", noop)
+	require.Equal(t, []string{"a", "a2", "b", "c"}, stageNames(p))
+
+	var replaced bool
+	p.Replace("b", PipeFunc(func(ctx context.Context, job *Job) (*Job, error) {
+		replaced = true
+		return job, nil
+	}))
+	_, err := p.Process(context.Background(), &Job{Item: frontier.Item{URL: "http://example.com"}})
+	require.NoError(t, err)
+	require.True(t, replaced)
+
+	p.Remove("a2")
+	require.Equal(t, []string{"a", "b", "c"}, stageNames(p))
+
+	// Unknown names are no-ops rather than errors.
+	p.InsertBefore("missing", "x", noop)
+	p.InsertAfter("missing", "y", noop)
+	p.Replace("missing", noop)
+	p.Remove("missing")
+	require.Equal(t, []string{"a", "b", "c"}, stageNames(p))
+}
+
+func stageNames(p *Pipeline) []string {
+	names := make([]string, len(p.stages))
+	for i, s := range p.stages {
+		names[i] = s.name
+	}
+	return names
+}
+
+func Test_NewDefaultPipeline_DelegatesToVisit(t *testing.T) {
+	originalProcessUrl := processUrl
+	originalGetNextUrls := getNextUrls
+	defer func() {
+		processUrl = originalProcessUrl
+		getNextUrls = originalGetNextUrls
+	}()
+
+	var visited string
+	processUrl = func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
+		visited = url
+		return new(goquery.Document), nil
+	}
+	getNextUrls = func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+		return []frontier.Item{{URL: "http://example.com/child", Depth: depth + 1}}
+	}
+
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	c := New("http://example.com", t.TempDir(), 0, logger)
+	p := NewDefaultPipeline(c)
+
+	job, err := p.Process(context.Background(), &Job{Item: frontier.Item{URL: "http://example.com"}})
+	require.NoError(t, err)
+	require.Equal(t, "http://example.com", visited)
+	require.Equal(t, []frontier.Item{{URL: "http://example.com/child", Depth: 1}}, job.Children)
+}
+
+func Test_Run_UsesCustomPipeline(t *testing.T) {
+	originalProcessUrl := processUrl
+	originalGetNextUrls := getNextUrls
+	defer func() {
+		processUrl = originalProcessUrl
+		getNextUrls = originalGetNextUrls
+	}()
+	processUrl = func(ctx context.Context, httpClient *http.Client, st storage.Storage, cp *checkpoint.Store, assetRewriter *assets.Rewriter, url string, politeness *robots.Policy, hl *hostLimiter, bl *rate.Limiter, fetcher Fetcher, userAgent string, log *log.Logger) (*goquery.Document, error) {
+		return new(goquery.Document), nil
+	}
+	getNextUrls = func(c *Crawler, doc *goquery.Document, depth int) []frontier.Item {
+		return nil
+	}
+
+	var processed int
+	custom := NewPipeline().Use("inspect", PipeFunc(func(ctx context.Context, job *Job) (*Job, error) {
+		processed++
+		return job, nil
+	}))
+
+	logger := log.New(os.Stdout, "UNIT TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	c := New("http://example.com", t.TempDir(), 0, logger, WithPipeline(custom))
+
+	err := c.Run(context.Background())
+	require.NoError(t, err)
+	require.Same(t, custom, c.pipeline)
+	require.Equal(t, 1, processed)
+
+	stats := c.PipelineStats()
+	require.Equal(t, 1, stats["inspect"].Processed)
+}
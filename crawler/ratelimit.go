@@ -0,0 +1,45 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+// hostLimiter enforces a maximum requests-per-second rate per host via
+// a token-bucket, independent of the MinDelay/Crawl-delay enforced by
+// robots.Policy and of how many workers are fetching concurrently.
+type hostLimiter struct {
+	rps   float64
+	mu    sync.Mutex
+	hosts map[string]*rate.Limiter
+}
+
+// newHostLimiter creates a hostLimiter allowing rps requests per second
+// to any single host, with a burst of one.
+func newHostLimiter(rps float64) *hostLimiter {
+	return &hostLimiter{rps: rps, hosts: make(map[string]*rate.Limiter)}
+}
+
+// wait blocks until it is permitted to fetch rawUrl under its host's
+// rate limit, or until ctx is done, whichever comes first.
+func (h *hostLimiter) wait(ctx context.Context, rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return errors.Wrapf(err, "parsing url %v", rawUrl)
+	}
+	h.mu.Lock()
+	limiter, ok := h.hosts[parsed.Host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(h.rps), 1)
+		h.hosts[parsed.Host] = limiter
+	}
+	h.mu.Unlock()
+	return limiter.Wait(ctx)
+}
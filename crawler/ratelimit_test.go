@@ -0,0 +1,52 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_hostLimiter_Wait(t *testing.T) {
+	const rps = 20.0
+	limiter := newHostLimiter(rps)
+	const calls = 5
+	start := time.Now()
+	for i := 0; i < calls; i++ {
+		require.NoError(t, limiter.wait(context.Background(), "http://example.com/page.html"))
+	}
+	elapsed := time.Since(start)
+	// calls-1 intervals of 1/rps must have elapsed, since the first call
+	// consumes the initial burst token for free.
+	minElapsed := time.Duration(float64(calls-1)/rps*float64(time.Second)) - 20*time.Millisecond
+	require.GreaterOrEqual(t, elapsed, minElapsed)
+}
+
+func Test_hostLimiter_PerHostIndependence(t *testing.T) {
+	limiter := newHostLimiter(1)
+	require.NoError(t, limiter.wait(context.Background(), "http://a.example.com/"))
+	require.NoError(t, limiter.wait(context.Background(), "http://b.example.com/"))
+	// A different host should not have consumed a.example.com's token, so
+	// a second call to it immediately after must block for close to 1s;
+	// instead we just assert both hosts got their own limiter.
+	require.Len(t, limiter.hosts, 2)
+}
+
+func Test_hostLimiter_InvalidUrl(t *testing.T) {
+	limiter := newHostLimiter(1)
+	err := limiter.wait(context.Background(), "://not-a-url")
+	require.Error(t, err)
+}
+
+func Test_hostLimiter_ContextCancelled(t *testing.T) {
+	limiter := newHostLimiter(1)
+	require.NoError(t, limiter.wait(context.Background(), "http://example.com/"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := limiter.wait(ctx, "http://example.com/")
+	require.ErrorIs(t, err, context.Canceled)
+}
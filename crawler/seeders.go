@@ -0,0 +1,207 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tcmsoftware/mirroring-web-crawler/seeds"
+)
+
+// Seeder discovers URLs to pre-populate a crawl with before its
+// link-following traversal begins. Discovered URLs are fed into the
+// same frontier, and so the same scope filters, as normally crawled
+// links.
+type Seeder interface {
+	// Seed returns the URLs discovered for startUrl. A source that is
+	// merely empty (nothing found) is not an error; Seed only returns
+	// an error when discovery itself failed.
+	Seed(ctx context.Context, httpClient *http.Client, userAgent, startUrl string) ([]string, error)
+}
+
+// seederFetch downloads url with userAgent as the User-Agent header
+// and any extra headers set, and returns its raw body, failing on any
+// status other than 200. For ease of unit testing, so we can inject
+// everything we need to.
+var seederFetch = func(ctx context.Context, httpClient *http.Client, userAgent, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s fetching %v", resp.Status, url)
+	}
+	return readAll(resp.Body)
+}
+
+// hostOf returns rawUrl's host, suitable for building a third-party
+// archive's per-host query.
+func hostOf(rawUrl string) (string, error) {
+	u, err := neturl.Parse(rawUrl)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing url %v", rawUrl)
+	}
+	if u.Host == "" {
+		return "", errors.Errorf("url %v has no host", rawUrl)
+	}
+	return u.Host, nil
+}
+
+// SitemapSeeder discovers URLs from a site's /sitemap.xml (recursing
+// into any sitemapindex) and any RSS/Atom feeds linked from the start
+// page. It is a thin wrapper around seeds.Discover.
+type SitemapSeeder struct {
+	// Since restricts results to entries with no lastmod/pubDate/
+	// updated timestamp, or one after Since. The zero value includes
+	// everything.
+	Since time.Time
+}
+
+// Seed implements Seeder.
+func (s SitemapSeeder) Seed(ctx context.Context, httpClient *http.Client, userAgent, startUrl string) ([]string, error) {
+	return seeds.Discover(ctx, httpClient, userAgent, startUrl, s.Since)
+}
+
+// waybackCDXURL is the Wayback Machine's CDX API endpoint. A var so
+// tests can point it at a fake server.
+var waybackCDXURL = "http://web.archive.org/cdx/search/cdx"
+
+// WaybackSeeder discovers URLs the Wayback Machine has archived for
+// the start URL's host, via its CDX API.
+type WaybackSeeder struct{}
+
+// Seed implements Seeder.
+func (WaybackSeeder) Seed(ctx context.Context, httpClient *http.Client, userAgent, startUrl string) ([]string, error) {
+	host, err := hostOf(startUrl)
+	if err != nil {
+		return nil, err
+	}
+	query := waybackCDXURL + "?url=" + neturl.QueryEscape(host+"/*") + "&output=json&fl=original&collapse=urlkey"
+	body, err := seederFetch(ctx, httpClient, userAgent, query, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying wayback cdx api")
+	}
+	var rows [][]string
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, errors.Wrap(err, "parsing wayback cdx response")
+	}
+	var urls []string
+	for i, row := range rows {
+		if i == 0 || len(row) == 0 {
+			// The first row is the column header ("original"), not a result.
+			continue
+		}
+		urls = append(urls, row[0])
+	}
+	return urls, nil
+}
+
+// commonCrawlIndexURL is the CommonCrawl index server endpoint queried
+// by CommonCrawlSeeder when Index is unset. A var so tests can point it
+// at a fake server.
+var commonCrawlIndexURL = "https://index.commoncrawl.org/CC-MAIN-2024-10-index"
+
+// CommonCrawlSeeder discovers URLs the CommonCrawl index has crawled
+// for the start URL's host.
+type CommonCrawlSeeder struct {
+	// Index overrides which CommonCrawl crawl index is queried.
+	// Defaults to commonCrawlIndexURL.
+	Index string
+}
+
+type commonCrawlRecord struct {
+	URL string `json:"url"`
+}
+
+// Seed implements Seeder.
+func (s CommonCrawlSeeder) Seed(ctx context.Context, httpClient *http.Client, userAgent, startUrl string) ([]string, error) {
+	host, err := hostOf(startUrl)
+	if err != nil {
+		return nil, err
+	}
+	index := s.Index
+	if index == "" {
+		index = commonCrawlIndexURL
+	}
+	query := index + "?url=" + neturl.QueryEscape(host+"/*") + "&output=json"
+	body, err := seederFetch(ctx, httpClient, userAgent, query, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying commoncrawl index")
+	}
+	// The index server responds with one JSON object per line, not a
+	// JSON array.
+	var urls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		if line == "" {
+			continue
+		}
+		var record commonCrawlRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, errors.Wrap(err, "parsing commoncrawl index line")
+		}
+		if record.URL != "" {
+			urls = append(urls, record.URL)
+		}
+	}
+	return urls, nil
+}
+
+// virusTotalAPIURL is VirusTotal's v3 domains API. A var so tests can
+// point it at a fake server.
+var virusTotalAPIURL = "https://www.virustotal.com/api/v3/domains"
+
+// VirusTotalSeeder discovers URLs VirusTotal has passively observed for
+// the start URL's host. Only the first page of results is fetched.
+type VirusTotalSeeder struct {
+	APIKey string
+}
+
+type virusTotalResponse struct {
+	Data []struct {
+		Attributes struct {
+			URL string `json:"url"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// Seed implements Seeder.
+func (s VirusTotalSeeder) Seed(ctx context.Context, httpClient *http.Client, userAgent, startUrl string) ([]string, error) {
+	host, err := hostOf(startUrl)
+	if err != nil {
+		return nil, err
+	}
+	query := virusTotalAPIURL + "/" + neturl.PathEscape(host) + "/urls"
+	body, err := seederFetch(ctx, httpClient, userAgent, query, map[string]string{"x-apikey": s.APIKey})
+	if err != nil {
+		return nil, errors.Wrap(err, "querying virustotal api")
+	}
+	var resp virusTotalResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "parsing virustotal response")
+	}
+	var urls []string
+	for _, d := range resp.Data {
+		if d.Attributes.URL != "" {
+			urls = append(urls, d.Attributes.URL)
+		}
+	}
+	return urls, nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WaybackSeeder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Contains(t, r.URL.Query().Get("url"), "example.com")
+		w.Write([]byte(`[["original"],["http://example.com/a.html"],["http://example.com/b.html"]]`))
+	}))
+	defer server.Close()
+	original := waybackCDXURL
+	waybackCDXURL = server.URL
+	defer func() { waybackCDXURL = original }()
+
+	urls, err := WaybackSeeder{}.Seed(context.Background(), new(http.Client), "somebot", "http://example.com/")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"http://example.com/a.html", "http://example.com/b.html"}, urls)
+}
+
+func Test_WaybackSeeder_InvalidStartUrl(t *testing.T) {
+	_, err := WaybackSeeder{}.Seed(context.Background(), new(http.Client), "somebot", ":not a url")
+	require.Error(t, err)
+}
+
+func Test_CommonCrawlSeeder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{\"url\":\"http://example.com/a.html\"}\n{\"url\":\"http://example.com/b.html\"}\n"))
+	}))
+	defer server.Close()
+
+	urls, err := CommonCrawlSeeder{Index: server.URL}.Seed(context.Background(), new(http.Client), "somebot", "http://example.com/")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"http://example.com/a.html", "http://example.com/b.html"}, urls)
+}
+
+func Test_VirusTotalSeeder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "sometoken", r.Header.Get("x-apikey"))
+		w.Write([]byte(`{"data":[{"attributes":{"url":"http://example.com/a.html"}}]}`))
+	}))
+	defer server.Close()
+	original := virusTotalAPIURL
+	virusTotalAPIURL = server.URL
+	defer func() { virusTotalAPIURL = original }()
+
+	urls, err := VirusTotalSeeder{APIKey: "sometoken"}.Seed(context.Background(), new(http.Client), "somebot", "http://example.com/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://example.com/a.html"}, urls)
+}
+
+func Test_SitemapSeeder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sitemap.xml" {
+			w.Write([]byte(`<urlset><url><loc>http://` + r.Host + `/page.html</loc></url></urlset>`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	urls, err := SitemapSeeder{}.Seed(context.Background(), new(http.Client), "somebot", server.URL)
+	require.NoError(t, err)
+	require.Equal(t, []string{server.URL + "/page.html"}, urls)
+}
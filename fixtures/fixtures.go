@@ -44,6 +44,16 @@ const (
 		</body>
 	</html>
 	`
+	OneLinkWithNofollow = `
+	<html>
+		<head>
+		</head>
+		<body>
+		<a href="/some_section/2023/01/19/page1.html" rel="nofollow">Page 1</a>
+		<a href="/some_section/2023/02/13/page2.html">Page 2</a>
+		</body>
+	</html>
+	`
 )
 
 func parseHtmlPage(htmlPage string) (*goquery.Document, error) {
@@ -0,0 +1,90 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package frontier
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// IncludeRegexp builds a Filter that only lets URLs matching re
+// through.
+func IncludeRegexp(re *regexp.Regexp) Filter {
+	return func(rawUrl string) bool {
+		return re.MatchString(rawUrl)
+	}
+}
+
+// ExcludeRegexp builds a Filter that rejects URLs matching re.
+func ExcludeRegexp(re *regexp.Regexp) Filter {
+	return func(rawUrl string) bool {
+		return !re.MatchString(rawUrl)
+	}
+}
+
+// PathPrefix builds a Filter that only lets URLs whose path starts
+// with prefix through, scoping a crawl to a section of a site.
+func PathPrefix(prefix string) Filter {
+	return func(rawUrl string) bool {
+		u, err := Normalize(rawUrl)
+		if err != nil {
+			return false
+		}
+		path := u
+		if idx := strings.Index(u, "://"); idx >= 0 {
+			if slash := strings.Index(u[idx+3:], "/"); slash >= 0 {
+				path = u[idx+3+slash:]
+			} else {
+				path = "/"
+			}
+		}
+		return strings.HasPrefix(path, prefix)
+	}
+}
+
+// AllowedDomains builds a Filter that only lets URLs whose host is one
+// of domains through. With includeSubdomains, a URL on any subdomain of
+// a listed domain is let through too.
+func AllowedDomains(domains []string, includeSubdomains bool) Filter {
+	return func(rawUrl string) bool {
+		host := hostOf(rawUrl)
+		if host == "" {
+			return false
+		}
+		for _, domain := range domains {
+			if host == domain {
+				return true
+			}
+			if includeSubdomains && strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// DomainBlacklist builds a Filter that rejects URLs whose host is, or
+// is a subdomain of, one of domains.
+func DomainBlacklist(domains []string) Filter {
+	return func(rawUrl string) bool {
+		host := hostOf(rawUrl)
+		for _, domain := range domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// hostOf returns rawUrl's lowercased hostname, or "" if it cannot be
+// parsed.
+func hostOf(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
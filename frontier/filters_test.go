@@ -0,0 +1,93 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package frontier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AllowedDomains(t *testing.T) {
+	testCases := []struct {
+		name              string
+		domains           []string
+		includeSubdomains bool
+		url               string
+		expected          bool
+	}{
+		{
+			name:     "exact domain match",
+			domains:  []string{"example.com"},
+			url:      "https://example.com/page.html",
+			expected: true,
+		},
+		{
+			name:     "different domain rejected",
+			domains:  []string{"example.com"},
+			url:      "https://other.com/page.html",
+			expected: false,
+		},
+		{
+			name:              "subdomain rejected without includeSubdomains",
+			domains:           []string{"example.com"},
+			includeSubdomains: false,
+			url:               "https://blog.example.com/page.html",
+			expected:          false,
+		},
+		{
+			name:              "subdomain accepted with includeSubdomains",
+			domains:           []string{"example.com"},
+			includeSubdomains: true,
+			url:               "https://blog.example.com/page.html",
+			expected:          true,
+		},
+		{
+			name:     "unparseable url rejected",
+			domains:  []string{"example.com"},
+			url:      ":://bad",
+			expected: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filter := AllowedDomains(tc.domains, tc.includeSubdomains)
+			require.Equal(t, tc.expected, filter(tc.url))
+		})
+	}
+}
+
+func Test_DomainBlacklist(t *testing.T) {
+	testCases := []struct {
+		name     string
+		domains  []string
+		url      string
+		expected bool
+	}{
+		{
+			name:     "blacklisted domain rejected",
+			domains:  []string{"ads.example.com"},
+			url:      "https://ads.example.com/banner.html",
+			expected: false,
+		},
+		{
+			name:     "subdomain of blacklisted domain rejected",
+			domains:  []string{"example.com"},
+			url:      "https://tracker.example.com/pixel.html",
+			expected: false,
+		},
+		{
+			name:     "unrelated domain accepted",
+			domains:  []string{"ads.example.com"},
+			url:      "https://example.com/page.html",
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filter := DomainBlacklist(tc.domains)
+			require.Equal(t, tc.expected, filter(tc.url))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// Package frontier tracks the URLs still to be crawled: how deep each
+// one is, how many have been accepted so far, and which ones have
+// already been seen, regardless of how they were spelled.
+package frontier
+
+import "sync"
+
+// Item is a URL paired with how many hops it is from the start URL.
+type Item struct {
+	URL   string
+	Depth int
+}
+
+// Filter decides whether a URL may enter the frontier. It returns
+// true to allow the URL through.
+type Filter func(rawUrl string) bool
+
+// Frontier deduplicates and bounds the set of URLs a crawl will visit.
+type Frontier struct {
+	maxDepth int // negative means unlimited
+	maxPages int // zero or negative means unlimited
+	filters  []Filter
+
+	mu      sync.Mutex
+	visited map[string]bool
+}
+
+// New creates a Frontier. maxDepth < 0 means no depth limit; maxPages
+// <= 0 means no page-count limit. Every filter must allow a URL for it
+// to be added.
+func New(maxDepth, maxPages int, filters ...Filter) *Frontier {
+	return &Frontier{
+		maxDepth: maxDepth,
+		maxPages: maxPages,
+		filters:  filters,
+		visited:  make(map[string]bool),
+	}
+}
+
+// Add reports whether rawUrl should be crawled at depth: it is new
+// (its normalized form was not seen before), within maxDepth, within
+// maxPages, and accepted by every filter. A true result marks rawUrl
+// as visited for the lifetime of the Frontier.
+func (f *Frontier) Add(rawUrl string, depth int) bool {
+	if f.maxDepth >= 0 && depth > f.maxDepth {
+		return false
+	}
+	normalized, err := Normalize(rawUrl)
+	if err != nil {
+		return false
+	}
+	for _, filter := range f.filters {
+		if !filter(rawUrl) {
+			return false
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.visited[normalized] {
+		return false
+	}
+	if f.maxPages > 0 && len(f.visited) >= f.maxPages {
+		return false
+	}
+	f.visited[normalized] = true
+	return true
+}
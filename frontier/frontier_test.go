@@ -0,0 +1,123 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package frontier
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Frontier_Add(t *testing.T) {
+	testCases := []struct {
+		name        string
+		maxDepth    int
+		maxPages    int
+		filters     []Filter
+		seed        []Item
+		url         string
+		depth       int
+		expectedAdd bool
+	}{
+		{
+			name:        "new url is added",
+			maxDepth:    -1,
+			url:         "http://example.com/page.html",
+			depth:       0,
+			expectedAdd: true,
+		},
+		{
+			name:        "duplicate normalized url is rejected",
+			seed:        []Item{{URL: "http://EXAMPLE.com:80/page.html", Depth: 0}},
+			url:         "http://example.com/page.html",
+			depth:       0,
+			expectedAdd: false,
+		},
+		{
+			name:        "depth beyond max depth is rejected",
+			maxDepth:    1,
+			url:         "http://example.com/page.html",
+			depth:       2,
+			expectedAdd: false,
+		},
+		{
+			name:        "depth within max depth is accepted",
+			maxDepth:    1,
+			url:         "http://example.com/page.html",
+			depth:       1,
+			expectedAdd: true,
+		},
+		{
+			name:        "max pages already reached",
+			maxPages:    1,
+			seed:        []Item{{URL: "http://example.com/other.html", Depth: 0}},
+			url:         "http://example.com/page.html",
+			depth:       0,
+			expectedAdd: false,
+		},
+		{
+			name:        "rejected by exclude filter",
+			filters:     []Filter{ExcludeRegexp(regexp.MustCompile(`\.pdf$`))},
+			url:         "http://example.com/page.pdf",
+			depth:       0,
+			expectedAdd: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := New(tc.maxDepth, tc.maxPages, tc.filters...)
+			for _, item := range tc.seed {
+				f.Add(item.URL, item.Depth)
+			}
+			require.Equal(t, tc.expectedAdd, f.Add(tc.url, tc.depth))
+		})
+	}
+}
+
+func Test_Normalize(t *testing.T) {
+	testCases := []struct {
+		name        string
+		url         string
+		expectedUrl string
+	}{
+		{
+			name:        "lowercases host",
+			url:         "http://EXAMPLE.com/page.html",
+			expectedUrl: "http://example.com/page.html",
+		},
+		{
+			name:        "strips default http port",
+			url:         "http://example.com:80/page.html",
+			expectedUrl: "http://example.com/page.html",
+		},
+		{
+			name:        "strips default https port",
+			url:         "https://example.com:443/page.html",
+			expectedUrl: "https://example.com/page.html",
+		},
+		{
+			name:        "keeps non-default port",
+			url:         "http://example.com:8080/page.html",
+			expectedUrl: "http://example.com:8080/page.html",
+		},
+		{
+			name:        "removes fragment",
+			url:         "http://example.com/page.html#section",
+			expectedUrl: "http://example.com/page.html",
+		},
+		{
+			name:        "sorts query params",
+			url:         "http://example.com/page.html?b=2&a=1",
+			expectedUrl: "http://example.com/page.html?a=1&b=2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			normalized, err := Normalize(tc.url)
+			require.Nil(t, err)
+			require.Equal(t, tc.expectedUrl, normalized)
+		})
+	}
+}
@@ -6,6 +6,7 @@
 package integration_test
 
 import (
+	"context"
 	"log"
 	"os"
 	"path/filepath"
@@ -22,7 +23,7 @@ func TestCrawler(t *testing.T) {
 	const timeout = 10 * time.Second
 	log := log.New(os.Stdout, "INTEGRATION TEST :", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
 	c := crawler.New(startUrl, destDir, timeout, log)
-	if err := c.Run(); err != nil {
+	if err := c.Run(context.Background()); err != nil {
 		t.Fatalf("error when running crawler: %v", err)
 	}
 	modTimes := make(map[string]time.Time, 200)
@@ -44,7 +45,7 @@ func TestCrawler(t *testing.T) {
 	// will be the same.
 	time.Sleep(1 * time.Second)
 	modTimesForSecondRun := make(map[string]time.Time, 200)
-	if err := c.Run(); err != nil {
+	if err := c.Run(context.Background()); err != nil {
 		t.Fatalf("error when running crawler: %v", err)
 	}
 	err = filepath.Walk(destDir,
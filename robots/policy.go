@@ -0,0 +1,121 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package robots
+
+import (
+	"context"
+	"net/http"
+	goUrl "net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// For ease of unit testing, so we can inject everything we need to.
+var (
+	get = func(httpClient *http.Client, url string) (*http.Response, error) {
+		return httpClient.Get(url)
+	}
+)
+
+// hostState tracks the cached Ruleset and the last time a URL on that
+// host was fetched, so callers can be rate-limited per host.
+type hostState struct {
+	ruleset   *Ruleset
+	lastFetch time.Time
+}
+
+// Policy fetches and caches robots.txt per host, and enforces the
+// crawl-delay (or MinDelay, whichever is larger) between requests to
+// the same host.
+type Policy struct {
+	UserAgent string
+	MinDelay  time.Duration
+
+	httpClient *http.Client
+	mu         sync.Mutex
+	hosts      map[string]*hostState
+}
+
+// NewPolicy creates a Policy that identifies itself as userAgent and
+// waits at least minDelay between requests to the same host.
+func NewPolicy(userAgent string, minDelay time.Duration, httpClient *http.Client) *Policy {
+	return &Policy{
+		UserAgent:  userAgent,
+		MinDelay:   minDelay,
+		httpClient: httpClient,
+		hosts:      make(map[string]*hostState),
+	}
+}
+
+// Allowed reports whether rawUrl may be fetched, fetching and caching
+// the host's robots.txt the first time it is seen.
+func (p *Policy) Allowed(rawUrl string) (bool, error) {
+	parsed, err := goUrl.Parse(rawUrl)
+	if err != nil {
+		return false, errors.Wrapf(err, "parsing url %v", rawUrl)
+	}
+	state := p.stateFor(parsed)
+	return state.ruleset.Allowed(p.UserAgent, parsed.Path), nil
+}
+
+// Wait blocks until it is polite to make another request to the host
+// in rawUrl, based on that host's Crawl-delay directive (or MinDelay,
+// whichever is larger), or until ctx is done, whichever comes first. It
+// must be called again after every request to that host.
+func (p *Policy) Wait(ctx context.Context, rawUrl string) error {
+	parsed, err := goUrl.Parse(rawUrl)
+	if err != nil {
+		return errors.Wrapf(err, "parsing url %v", rawUrl)
+	}
+	state := p.stateFor(parsed)
+	delay := state.ruleset.CrawlDelay(p.UserAgent)
+	if p.MinDelay > delay {
+		delay = p.MinDelay
+	}
+	p.mu.Lock()
+	now := time.Now()
+	wait := state.lastFetch.Add(delay).Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	state.lastFetch = now.Add(wait)
+	p.mu.Unlock()
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (p *Policy) stateFor(parsed *goUrl.URL) *hostState {
+	p.mu.Lock()
+	state, ok := p.hosts[parsed.Host]
+	if !ok {
+		state = &hostState{ruleset: p.fetchRuleset(parsed)}
+		p.hosts[parsed.Host] = state
+	}
+	p.mu.Unlock()
+	return state
+}
+
+// fetchRuleset fetches /robots.txt for the host in parsed. Any error,
+// including a non-200 response, results in an empty Ruleset, which
+// allows everything -- a host with no (or unreachable) robots.txt
+// imposes no restrictions.
+func (p *Policy) fetchRuleset(parsed *goUrl.URL) *Ruleset {
+	robotsUrl := parsed.Scheme + "://" + parsed.Host + "/robots.txt"
+	resp, err := get(p.httpClient, robotsUrl)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return &Ruleset{}
+	}
+	defer resp.Body.Close()
+	return Parse(resp.Body)
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// Package robots parses robots.txt files and answers whether a given
+// user-agent is allowed to fetch a given path, plus any crawl-delay
+// the site asks for.
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rule is a single Allow/Disallow entry scoped to the group it belongs to.
+type rule struct {
+	path  string
+	allow bool
+}
+
+// group holds every rule and the crawl-delay for one or more
+// `User-agent` lines in a robots.txt file.
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+func (g *group) matches(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, agent := range g.agents {
+		if agent == "*" {
+			return true
+		}
+		if strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ruleset is a parsed robots.txt file.
+type Ruleset struct {
+	groups []*group
+	// Sitemaps are the URLs from the file's Sitemap: directives, which
+	// apply to every user-agent and so aren't scoped to a group.
+	Sitemaps []string
+}
+
+// Parse reads a robots.txt document and returns the parsed Ruleset.
+func Parse(r io.Reader) *Ruleset {
+	rs := &Ruleset{}
+	var current *group
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+		switch field {
+		case "user-agent":
+			agent := strings.ToLower(value)
+			if current == nil || len(current.rules) > 0 || current.crawlDelay > 0 {
+				current = &group{}
+				rs.groups = append(rs.groups, current)
+			}
+			current.agents = append(current.agents, agent)
+		case "disallow":
+			if current == nil {
+				continue
+			}
+			if value != "" {
+				current.rules = append(current.rules, rule{path: value, allow: false})
+			}
+		case "allow":
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, rule{path: value, allow: true})
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			if value != "" {
+				rs.Sitemaps = append(rs.Sitemaps, value)
+			}
+		}
+	}
+	return rs
+}
+
+// groupFor returns the first group that names a user-agent contained in
+// userAgent, falling back to the first wildcard-only group, or nil if
+// no group applies.
+func (rs *Ruleset) groupFor(userAgent string) *group {
+	var wildcard *group
+	for _, g := range rs.groups {
+		if g.matches(userAgent) {
+			isWildcard := len(g.agents) == 1 && g.agents[0] == "*"
+			if !isWildcard {
+				return g
+			}
+			if wildcard == nil {
+				wildcard = g
+			}
+		}
+	}
+	return wildcard
+}
+
+// Allowed reports whether userAgent may fetch path according to the
+// ruleset. The longest matching rule wins, as specified by the Robots
+// Exclusion Protocol; ties favor Allow.
+func (rs *Ruleset) Allowed(userAgent, path string) bool {
+	g := rs.groupFor(userAgent)
+	if g == nil {
+		return true
+	}
+	allowed := true
+	longest := -1
+	for _, r := range g.rules {
+		if !strings.HasPrefix(path, r.path) {
+			continue
+		}
+		if len(r.path) > longest || (len(r.path) == longest && r.allow) {
+			longest = len(r.path)
+			allowed = r.allow
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the crawl-delay requested for userAgent, or zero
+// if the robots.txt file did not specify one.
+func (rs *Ruleset) CrawlDelay(userAgent string) time.Duration {
+	g := rs.groupFor(userAgent)
+	if g == nil {
+		return 0
+	}
+	return g.crawlDelay
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package robots
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Ruleset_Allowed(t *testing.T) {
+	testCases := []struct {
+		name            string
+		robotsTxt       string
+		userAgent       string
+		path            string
+		expectedAllowed bool
+	}{
+		{
+			name:            "no rules means everything is allowed",
+			robotsTxt:       "",
+			userAgent:       "somebot",
+			path:            "/private/page.html",
+			expectedAllowed: true,
+		},
+		{
+			name: "disallowed path under wildcard group",
+			robotsTxt: `
+				User-agent: *
+				Disallow: /private/
+			`,
+			userAgent:       "somebot",
+			path:            "/private/page.html",
+			expectedAllowed: false,
+		},
+		{
+			name: "allowed path outside disallowed prefix",
+			robotsTxt: `
+				User-agent: *
+				Disallow: /private/
+			`,
+			userAgent:       "somebot",
+			path:            "/public/page.html",
+			expectedAllowed: true,
+		},
+		{
+			name: "more specific allow overrides shorter disallow",
+			robotsTxt: `
+				User-agent: *
+				Disallow: /private/
+				Allow: /private/public-page.html
+			`,
+			userAgent:       "somebot",
+			path:            "/private/public-page.html",
+			expectedAllowed: true,
+		},
+		{
+			name: "agent-specific group takes precedence over wildcard",
+			robotsTxt: `
+				User-agent: *
+				Disallow: /
+
+				User-agent: somebot
+				Disallow:
+			`,
+			userAgent:       "somebot",
+			path:            "/page.html",
+			expectedAllowed: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs := Parse(strings.NewReader(tc.robotsTxt))
+			require.Equal(t, tc.expectedAllowed, rs.Allowed(tc.userAgent, tc.path))
+		})
+	}
+}
+
+func Test_Ruleset_CrawlDelay(t *testing.T) {
+	robotsTxt := `
+		User-agent: *
+		Crawl-delay: 2
+	`
+	rs := Parse(strings.NewReader(robotsTxt))
+	require.Equal(t, 2*time.Second, rs.CrawlDelay("somebot"))
+	require.Equal(t, time.Duration(0), (&Ruleset{}).CrawlDelay("somebot"))
+}
+
+func Test_Ruleset_Sitemaps(t *testing.T) {
+	robotsTxt := `
+		User-agent: *
+		Disallow: /private/
+		Sitemap: https://example.com/sitemap.xml
+		Sitemap: https://example.com/sitemap-news.xml
+	`
+	rs := Parse(strings.NewReader(robotsTxt))
+	require.Equal(t, []string{
+		"https://example.com/sitemap.xml",
+		"https://example.com/sitemap-news.xml",
+	}, rs.Sitemaps)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// Package search extracts and records the specific content a crawl is
+// looking for -- pages matching a regexp, images by file extension, and
+// arbitrary CSS-selected elements -- separately from the mirrored copy
+// of each page.
+package search
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+)
+
+// Config describes what a crawl should extract from each page it
+// fetches.
+type Config struct {
+	// PageRegexp, if set, restricts extraction to pages whose text
+	// matches it. Unset matches every page.
+	PageRegexp *regexp.Regexp
+	// ImageExtensions lists the file extensions (e.g. ".jpg") an img
+	// src is matched against, case-insensitively.
+	ImageExtensions []string
+	// Selectors are CSS selectors whose matching elements' text is
+	// recorded, keyed by the selector itself.
+	Selectors []string
+}
+
+// Match is what Extract found on a single page.
+type Match struct {
+	URL       string              `json:"url"`
+	Images    []string            `json:"images,omitempty"`
+	Selectors map[string][]string `json:"selectors,omitempty"`
+}
+
+// Extract reports whether pageUrl/doc is of interest per cfg -- that
+// is, cfg.PageRegexp is unset or matches the page's text -- and if so,
+// the images and selector matches found on it.
+func Extract(cfg Config, pageUrl string, doc *goquery.Document) (Match, bool) {
+	if cfg.PageRegexp != nil && !cfg.PageRegexp.MatchString(doc.Text()) {
+		return Match{}, false
+	}
+	match := Match{URL: pageUrl}
+	if len(cfg.ImageExtensions) > 0 {
+		doc.Find("img[src]").Each(func(_ int, s *goquery.Selection) {
+			src, _ := s.Attr("src")
+			lower := strings.ToLower(src)
+			for _, ext := range cfg.ImageExtensions {
+				if strings.HasSuffix(lower, strings.ToLower(ext)) {
+					match.Images = append(match.Images, src)
+					return
+				}
+			}
+		})
+	}
+	if len(cfg.Selectors) > 0 {
+		match.Selectors = make(map[string][]string)
+		for _, selector := range cfg.Selectors {
+			doc.Find(selector).Each(func(_ int, s *goquery.Selection) {
+				if text := strings.TrimSpace(s.Text()); text != "" {
+					match.Selectors[selector] = append(match.Selectors[selector], text)
+				}
+			})
+		}
+	}
+	return match, true
+}
+
+// Recorder appends Matches to a JSON-lines file as they are found, so
+// results survive a crawl that is interrupted partway through.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder creates a Recorder appending to path, creating the file
+// (and any missing contents) if it does not already exist.
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening search results file %s", path)
+	}
+	return &Recorder{file: file}, nil
+}
+
+// Record appends match to the file as a single JSON line.
+func (r *Recorder) Record(match Match) error {
+	data, err := json.Marshal(match)
+	if err != nil {
+		return errors.Wrap(err, "marshaling search match")
+	}
+	data = append(data, '\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.file.Write(data); err != nil {
+		return errors.Wrapf(err, "writing search match for %s", match.URL)
+	}
+	return nil
+}
+
+// Close closes the underlying file.
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package search
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/require"
+)
+
+func parseHtml(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	require.NoError(t, err)
+	return doc
+}
+
+func Test_Extract(t *testing.T) {
+	html := `<html><body>
+		<h1 class="title">Hello World</h1>
+		<img src="/logo.PNG">
+		<img src="/photo.jpg">
+		<img src="/icon.svg">
+	</body></html>`
+
+	testCases := []struct {
+		name     string
+		cfg      Config
+		expected Match
+		ok       bool
+	}{
+		{
+			name:     "page regexp matches",
+			cfg:      Config{PageRegexp: regexp.MustCompile(`Hello World`)},
+			ok:       true,
+			expected: Match{URL: "http://example.com/page.html"},
+		},
+		{
+			name: "page regexp does not match",
+			cfg:  Config{PageRegexp: regexp.MustCompile(`Goodbye`)},
+			ok:   false,
+		},
+		{
+			name: "images matched case-insensitively by extension",
+			cfg:  Config{ImageExtensions: []string{".png", ".jpg"}},
+			ok:   true,
+			expected: Match{
+				URL:    "http://example.com/page.html",
+				Images: []string{"/logo.PNG", "/photo.jpg"},
+			},
+		},
+		{
+			name: "selectors matched",
+			cfg:  Config{Selectors: []string{"h1.title"}},
+			ok:   true,
+			expected: Match{
+				URL:       "http://example.com/page.html",
+				Selectors: map[string][]string{"h1.title": {"Hello World"}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			match, ok := Extract(tc.cfg, "http://example.com/page.html", parseHtml(t, html))
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, tc.expected, match)
+			}
+		})
+	}
+}
+
+func Test_Recorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	rec, err := NewRecorder(path)
+	require.NoError(t, err)
+
+	require.NoError(t, rec.Record(Match{URL: "http://example.com/a.html"}))
+	require.NoError(t, rec.Record(Match{URL: "http://example.com/b.html"}))
+	require.NoError(t, rec.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var first Match
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.Equal(t, "http://example.com/a.html", first.URL)
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package seeds
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+)
+
+// feedRoot is decoded first to tell an Atom feed apart from an RSS
+// channel before the matching struct is decoded.
+type feedRoot struct {
+	XMLName xml.Name
+}
+
+type rssFeed struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Link    string `xml:"link"`
+	PubDate string `xml:"pubDate"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Links   []atomLink `xml:"link"`
+	Updated string     `xml:"updated"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// discoverFeeds fetches startUrl's page, finds every
+// <link rel="alternate" type="application/rss+xml|application/atom+xml">
+// it advertises, and returns the URL of every entry in those feeds with
+// no pubDate/updated timestamp or one after since. startUrl itself
+// being unreachable is not an error here; it is reported when the
+// crawl proper fetches it.
+func discoverFeeds(ctx context.Context, httpClient *http.Client, userAgent, startUrl string, since time.Time) ([]string, error) {
+	body, err := fetch(ctx, httpClient, userAgent, startUrl)
+	if err != nil {
+		return nil, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %v", startUrl)
+	}
+
+	var feedUrls []string
+	doc.Find(`link[rel="alternate"]`).Each(func(_ int, s *goquery.Selection) {
+		switch s.AttrOr("type", "") {
+		case "application/rss+xml", "application/atom+xml":
+			if href, ok := s.Attr("href"); ok {
+				feedUrls = append(feedUrls, resolve(startUrl, href))
+			}
+		}
+	})
+
+	var urls []string
+	for _, feedUrl := range feedUrls {
+		body, err := fetch(ctx, httpClient, userAgent, feedUrl)
+		if err != nil {
+			continue
+		}
+		entries, err := parseFeed(body, since)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing feed %v", feedUrl)
+		}
+		urls = append(urls, entries...)
+	}
+	return urls, nil
+}
+
+func parseFeed(body []byte, since time.Time) ([]string, error) {
+	var root feedRoot
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return nil, err
+	}
+	switch root.XMLName.Local {
+	case "rss":
+		var feed rssFeed
+		if err := xml.Unmarshal(body, &feed); err != nil {
+			return nil, err
+		}
+		var urls []string
+		for _, item := range feed.Channel.Items {
+			if item.Link != "" && newEnough(item.PubDate, since) {
+				urls = append(urls, item.Link)
+			}
+		}
+		return urls, nil
+	case "feed":
+		var feed atomFeed
+		if err := xml.Unmarshal(body, &feed); err != nil {
+			return nil, err
+		}
+		var urls []string
+		for _, entry := range feed.Entries {
+			href := alternateLink(entry.Links)
+			if href != "" && newEnough(entry.Updated, since) {
+				urls = append(urls, href)
+			}
+		}
+		return urls, nil
+	default:
+		return nil, nil
+	}
+}
+
+// alternateLink returns the first link with rel="alternate" (or no rel
+// at all, the default per the Atom spec), falling back to the first
+// link if neither is present.
+func alternateLink(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+func resolve(base, ref string) string {
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refUrl, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseUrl.ResolveReference(refUrl).String()
+}
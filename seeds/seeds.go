@@ -0,0 +1,95 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// Package seeds discovers URLs to pre-populate a crawl with before its
+// link-following traversal begins: the site's /sitemap.xml (following
+// any sitemapindex recursively, transparently handling gzip), and any
+// RSS/Atom feeds advertised on the start page.
+package seeds
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Fetcher downloads url and returns its body, transparently
+// decompressing it if it is gzipped. For ease of unit testing, so we
+// can inject everything we need to.
+type Fetcher func(ctx context.Context, httpClient *http.Client, userAgent, url string) ([]byte, error)
+
+// For ease of unit testing, so we can inject everything we need to.
+var fetch Fetcher = defaultFetcher
+
+func defaultFetcher(ctx context.Context, httpClient *http.Client, userAgent, rawUrl string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s fetching %v", resp.Status, rawUrl)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading response body for %v", rawUrl)
+	}
+	if resp.Header.Get("Content-Encoding") == "gzip" || strings.HasSuffix(rawUrl, ".gz") {
+		return gunzip(body)
+	}
+	return body, nil
+}
+
+func gunzip(body []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "decompressing gzip body")
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Discover returns the URLs advertised by startUrl's /sitemap.xml and
+// by any Sitemap: directives in its /robots.txt (recursing into any
+// sitemap indexes either points to), and by any RSS/Atom feeds linked
+// from startUrl's page, restricted to entries with no lastmod/pubDate/
+// updated timestamp or one after since. A missing or unreachable
+// robots.txt, sitemap, or feed is not an error, it just means there is
+// nothing to seed from it.
+func Discover(ctx context.Context, httpClient *http.Client, userAgent, startUrl string, since time.Time) ([]string, error) {
+	smUrl, err := sitemapPath(startUrl)
+	if err != nil {
+		return nil, err
+	}
+	visited := make(map[string]bool)
+	sitemapUrls, err := crawlSitemap(ctx, httpClient, userAgent, smUrl, since, visited)
+	if err != nil {
+		return nil, err
+	}
+	for _, robotsSmUrl := range robotsTxtSitemaps(ctx, httpClient, userAgent, startUrl) {
+		more, err := crawlSitemap(ctx, httpClient, userAgent, robotsSmUrl, since, visited)
+		if err != nil {
+			return nil, err
+		}
+		sitemapUrls = append(sitemapUrls, more...)
+	}
+	feedUrls, err := discoverFeeds(ctx, httpClient, userAgent, startUrl, since)
+	if err != nil {
+		return nil, err
+	}
+	return append(sitemapUrls, feedUrls...), nil
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package seeds
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withFakeFetch(t *testing.T, bodies map[string]string) {
+	t.Helper()
+	original := fetch
+	fetch = func(ctx context.Context, httpClient *http.Client, userAgent, url string) ([]byte, error) {
+		body, ok := bodies[url]
+		if !ok {
+			return nil, errorNotFound(url)
+		}
+		return []byte(body), nil
+	}
+	t.Cleanup(func() { fetch = original })
+}
+
+type notFoundError struct{ url string }
+
+func (e notFoundError) Error() string { return "not found: " + e.url }
+
+func errorNotFound(url string) error { return notFoundError{url} }
+
+func Test_Discover_Sitemap(t *testing.T) {
+	withFakeFetch(t, map[string]string{
+		"http://example.com/sitemap.xml": `
+			<urlset>
+				<url><loc>http://example.com/old.html</loc><lastmod>2020-01-01</lastmod></url>
+				<url><loc>http://example.com/new.html</loc><lastmod>2024-06-01</lastmod></url>
+				<url><loc>http://example.com/nodate.html</loc></url>
+			</urlset>`,
+	})
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	urls, err := Discover(context.Background(), new(http.Client), "someagent", "http://example.com/", since)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"http://example.com/new.html", "http://example.com/nodate.html"}, urls)
+}
+
+func Test_Discover_SitemapIndex(t *testing.T) {
+	withFakeFetch(t, map[string]string{
+		"http://example.com/sitemap.xml": `
+			<sitemapindex>
+				<sitemap><loc>http://example.com/sitemap-posts.xml</loc></sitemap>
+				<sitemap><loc>http://example.com/sitemap-pages.xml</loc></sitemap>
+			</sitemapindex>`,
+		"http://example.com/sitemap-posts.xml": `
+			<urlset><url><loc>http://example.com/post1.html</loc></url></urlset>`,
+		"http://example.com/sitemap-pages.xml": `
+			<urlset><url><loc>http://example.com/about.html</loc></url></urlset>`,
+	})
+	urls, err := Discover(context.Background(), new(http.Client), "someagent", "http://example.com/", time.Time{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"http://example.com/post1.html", "http://example.com/about.html"}, urls)
+}
+
+func Test_Discover_RobotsTxtSitemap(t *testing.T) {
+	withFakeFetch(t, map[string]string{
+		"http://example.com/robots.txt": "User-agent: *\nSitemap: http://example.com/sitemap-news.xml\n",
+		"http://example.com/sitemap-news.xml": `
+			<urlset><url><loc>http://example.com/news1.html</loc></url></urlset>`,
+	})
+	urls, err := Discover(context.Background(), new(http.Client), "someagent", "http://example.com/", time.Time{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"http://example.com/news1.html"}, urls)
+}
+
+func Test_Discover_MissingSitemapIsNotAnError(t *testing.T) {
+	withFakeFetch(t, map[string]string{})
+	urls, err := Discover(context.Background(), new(http.Client), "someagent", "http://example.com/", time.Time{})
+	require.NoError(t, err)
+	require.Empty(t, urls)
+}
+
+func Test_Discover_RssFeed(t *testing.T) {
+	withFakeFetch(t, map[string]string{
+		"http://example.com/": `
+			<html><head>
+				<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+			</head></html>`,
+		"http://example.com/feed.xml": `
+			<rss><channel>
+				<item><link>http://example.com/old-post.html</link><pubDate>Mon, 01 Jan 2020 00:00:00 +0000</pubDate></item>
+				<item><link>http://example.com/new-post.html</link><pubDate>Sat, 01 Jun 2024 00:00:00 +0000</pubDate></item>
+			</channel></rss>`,
+	})
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	urls, err := Discover(context.Background(), new(http.Client), "someagent", "http://example.com/", since)
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://example.com/new-post.html"}, urls)
+}
+
+func Test_Discover_AtomFeed(t *testing.T) {
+	withFakeFetch(t, map[string]string{
+		"http://example.com/": `
+			<html><head>
+				<link rel="alternate" type="application/atom+xml" href="/feed.atom">
+			</head></html>`,
+		"http://example.com/feed.atom": `
+			<feed>
+				<entry>
+					<link rel="alternate" href="http://example.com/entry1.html"/>
+					<updated>2024-06-01T00:00:00Z</updated>
+				</entry>
+			</feed>`,
+	})
+	urls, err := Discover(context.Background(), new(http.Client), "someagent", "http://example.com/", time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://example.com/entry1.html"}, urls)
+}
+
+func Test_Discover_MissingFeedIsNotAnError(t *testing.T) {
+	withFakeFetch(t, map[string]string{
+		"http://example.com/": `
+			<html><head>
+				<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+			</head></html>`,
+	})
+	urls, err := Discover(context.Background(), new(http.Client), "someagent", "http://example.com/", time.Time{})
+	require.NoError(t, err)
+	require.Empty(t, urls)
+}
+
+func Test_newEnough(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		since    time.Time
+		expected bool
+	}{
+		{
+			name:     "no since set",
+			value:    "2020-01-01",
+			expected: true,
+		},
+		{
+			name:     "no value",
+			since:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name:     "before since",
+			value:    "2020-01-01",
+			since:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name:     "after since",
+			value:    "2024-01-01",
+			since:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		{
+			name:     "unparseable value is kept",
+			value:    "not a date",
+			since:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, newEnough(tc.value, tc.since))
+		})
+	}
+}
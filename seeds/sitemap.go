@@ -0,0 +1,141 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package seeds
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tcmsoftware/mirroring-web-crawler/robots"
+)
+
+// sitemapRoot is decoded first to tell a urlset apart from a
+// sitemapindex before the matching struct is decoded.
+type sitemapRoot struct {
+	XMLName xml.Name
+}
+
+type urlSet struct {
+	URLs []sitemapEntry `xml:"url"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapIndex struct {
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+type sitemapRef struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapPath returns the /sitemap.xml URL for the site rawUrl belongs
+// to.
+func sitemapPath(rawUrl string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing url %v", rawUrl)
+	}
+	u.Path = "/sitemap.xml"
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// robotsTxtSitemaps returns the Sitemap: URLs advertised by startUrl's
+// /robots.txt. A missing or unreachable robots.txt yields no URLs
+// rather than an error.
+func robotsTxtSitemaps(ctx context.Context, httpClient *http.Client, userAgent, startUrl string) []string {
+	u, err := url.Parse(startUrl)
+	if err != nil {
+		return nil
+	}
+	u.Path = "/robots.txt"
+	u.RawQuery = ""
+	u.Fragment = ""
+	body, err := fetch(ctx, httpClient, userAgent, u.String())
+	if err != nil {
+		return nil
+	}
+	return robots.Parse(bytes.NewReader(body)).Sitemaps
+}
+
+// crawlSitemap fetches sitemapUrl and, if it is a sitemapindex,
+// recurses into every sitemap it lists; visited guards against cycles
+// between index files. It returns the <loc> of every urlset entry with
+// no <lastmod> or one after since.
+func crawlSitemap(ctx context.Context, httpClient *http.Client, userAgent, sitemapUrl string, since time.Time, visited map[string]bool) ([]string, error) {
+	if visited[sitemapUrl] {
+		return nil, nil
+	}
+	visited[sitemapUrl] = true
+
+	body, err := fetch(ctx, httpClient, userAgent, sitemapUrl)
+	if err != nil {
+		return nil, nil
+	}
+
+	var root sitemapRoot
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return nil, errors.Wrapf(err, "parsing sitemap %v", sitemapUrl)
+	}
+
+	switch root.XMLName.Local {
+	case "sitemapindex":
+		var index sitemapIndex
+		if err := xml.Unmarshal(body, &index); err != nil {
+			return nil, errors.Wrapf(err, "parsing sitemap index %v", sitemapUrl)
+		}
+		var urls []string
+		for _, ref := range index.Sitemaps {
+			nested, err := crawlSitemap(ctx, httpClient, userAgent, ref.Loc, since, visited)
+			if err != nil {
+				return nil, err
+			}
+			urls = append(urls, nested...)
+		}
+		return urls, nil
+	case "urlset":
+		var set urlSet
+		if err := xml.Unmarshal(body, &set); err != nil {
+			return nil, errors.Wrapf(err, "parsing sitemap %v", sitemapUrl)
+		}
+		var urls []string
+		for _, entry := range set.URLs {
+			if entry.Loc != "" && newEnough(entry.LastMod, since) {
+				urls = append(urls, entry.Loc)
+			}
+		}
+		return urls, nil
+	default:
+		return nil, nil
+	}
+}
+
+// timeLayouts are the timestamp formats seen in sitemap <lastmod> and
+// feed <pubDate>/<updated> elements, tried in order.
+var timeLayouts = []string{time.RFC3339, time.RFC1123Z, time.RFC1123, "2006-01-02"}
+
+// newEnough reports whether value, a timestamp in one of timeLayouts
+// (or empty), is after since. An empty value or zero since are both
+// treated as "include it": there is no information to exclude it on.
+func newEnough(value string, since time.Time) bool {
+	if since.IsZero() || value == "" {
+		return true
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.After(since)
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package storage
+
+import (
+	"io/fs"
+	"net/http"
+	goUrl "net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// For ease of unit testing, so we can inject everything we need to.
+var (
+	parseUrl         = goUrl.Parse
+	osStat           = os.Stat
+	osMkdirAll       = os.MkdirAll
+	osCreate         = os.Create
+	writeBytesToFile = func(f *os.File, data []byte) (int, error) {
+		return f.Write(data)
+	}
+)
+
+// FileStorage mirrors pages onto disk as a directory tree, one file
+// per page, the same layout `wget --mirror` produces.
+type FileStorage struct {
+	destDir string
+}
+
+// NewFileStorage creates a FileStorage that writes pages under destDir.
+func NewFileStorage(destDir string) *FileStorage {
+	return &FileStorage{destDir: destDir}
+}
+
+func (s *FileStorage) pagePath(url string) (string, error) {
+	parsedUrl, err := parseUrl(url)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing url %v", url)
+	}
+	if strings.HasSuffix(parsedUrl.Path, "/") {
+		return path.Join(s.destDir, parsedUrl.Host, parsedUrl.Path, "index.html"), nil
+	}
+	return path.Join(s.destDir, parsedUrl.Host, parsedUrl.Path), nil
+}
+
+// Exists reports whether url was already mirrored to disk.
+func (s *FileStorage) Exists(url string) bool {
+	pagePath, err := s.pagePath(url)
+	if err != nil {
+		return false
+	}
+	_, err = osStat(pagePath)
+	return err == nil
+}
+
+// Put writes body to the on-disk path derived from url, creating any
+// missing parent directories. The status code and response headers are
+// not persisted by this backend; use WarcStorage if they are needed.
+func (s *FileStorage) Put(url string, statusCode int, headers http.Header, body []byte) error {
+	pagePath, err := s.pagePath(url)
+	if err != nil {
+		return err
+	}
+	if err := osMkdirAll(path.Dir(pagePath), fs.ModePerm); err != nil {
+		return errors.Wrapf(err, "creating directory for %s", url)
+	}
+	file, err := osCreate(pagePath)
+	if err != nil {
+		return errors.Wrapf(err, "creating file for %s", url)
+	}
+	defer file.Close()
+	if _, err := writeBytesToFile(file, body); err != nil {
+		return errors.Wrapf(err, "writing file for %s", url)
+	}
+	return nil
+}
+
+// Close is a no-op: FileStorage holds no resources between calls to
+// Put.
+func (s *FileStorage) Close() error {
+	return nil
+}
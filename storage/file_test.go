@@ -0,0 +1,170 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	goUrl "net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FileStorage_pagePath(t *testing.T) {
+	testCases := []struct {
+		name          string
+		url           string
+		mockParseUrl  func(rawURL string) (*goUrl.URL, error)
+		expectedPath  string
+		expectedError error
+	}{
+		{
+			name:         "adds index.html for root path",
+			url:          "https://blog.cleancoder.com/",
+			expectedPath: "destDir/blog.cleancoder.com/index.html",
+		},
+		{
+			name:         "adds uri",
+			url:          "https://blog.cleancoder.com/uncle-bob/2019/02/01/somePage.html",
+			expectedPath: "destDir/blog.cleancoder.com/uncle-bob/2019/02/01/somePage.html",
+		},
+		{
+			name: "error",
+			url:  "https://blog.cleancoder.com/",
+			mockParseUrl: func(rawURL string) (*goUrl.URL, error) {
+				return nil, errors.New("random error")
+			},
+			expectedError: errors.New("parsing url https://blog.cleancoder.com/: random error"),
+		},
+	}
+	originalParseUrl := parseUrl
+	s := NewFileStorage("destDir")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.mockParseUrl != nil {
+				parseUrl = tc.mockParseUrl
+			} else {
+				parseUrl = originalParseUrl
+			}
+			path, err := s.pagePath(tc.url)
+			if tc.expectedError != nil {
+				require.EqualError(t, err, tc.expectedError.Error())
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tc.expectedPath, path)
+			}
+		})
+	}
+	parseUrl = originalParseUrl
+}
+
+func Test_FileStorage_Exists(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mockOsStat     func(name string) (fs.FileInfo, error)
+		expectedExists bool
+	}{
+		{
+			name: "exists",
+			mockOsStat: func(name string) (fs.FileInfo, error) {
+				return nil, nil
+			},
+			expectedExists: true,
+		},
+		{
+			name: "does not exist",
+			mockOsStat: func(name string) (fs.FileInfo, error) {
+				return nil, errors.New("random error")
+			},
+			expectedExists: false,
+		},
+	}
+	originalOsStat := osStat
+	defer func() { osStat = originalOsStat }()
+	s := NewFileStorage("destDir")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			osStat = tc.mockOsStat
+			require.Equal(t, tc.expectedExists, s.Exists("https://blog.cleancoder.com/page.html"))
+		})
+	}
+}
+
+func Test_FileStorage_Put(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		mockOsMkdirAll       func(path string, perm fs.FileMode) error
+		mockOsCreate         func(name string) (*os.File, error)
+		mockWriteBytesToFile func(f *os.File, data []byte) (int, error)
+		expectedError        error
+	}{
+		{
+			name: "happy path",
+			mockOsMkdirAll: func(path string, perm fs.FileMode) error {
+				return nil
+			},
+			mockOsCreate: func(name string) (*os.File, error) {
+				return new(os.File), nil
+			},
+			mockWriteBytesToFile: func(f *os.File, data []byte) (int, error) {
+				return len(data), nil
+			},
+		},
+		{
+			name: "error creating dir",
+			mockOsMkdirAll: func(path string, perm fs.FileMode) error {
+				return errors.New("random error")
+			},
+			expectedError: errors.New("creating directory for someurl: random error"),
+		},
+		{
+			name: "error creating file",
+			mockOsMkdirAll: func(path string, perm fs.FileMode) error {
+				return nil
+			},
+			mockOsCreate: func(name string) (*os.File, error) {
+				return nil, errors.New("random error")
+			},
+			expectedError: errors.New("creating file for someurl: random error"),
+		},
+		{
+			name: "error writing file",
+			mockOsMkdirAll: func(path string, perm fs.FileMode) error {
+				return nil
+			},
+			mockOsCreate: func(name string) (*os.File, error) {
+				return new(os.File), nil
+			},
+			mockWriteBytesToFile: func(f *os.File, data []byte) (int, error) {
+				return 0, errors.New("random error")
+			},
+			expectedError: errors.New("writing file for someurl: random error"),
+		},
+	}
+	originalOsMkdirAll := osMkdirAll
+	originalOsCreate := osCreate
+	originalWriteBytesToFile := writeBytesToFile
+	defer func() {
+		osMkdirAll = originalOsMkdirAll
+		osCreate = originalOsCreate
+		writeBytesToFile = originalWriteBytesToFile
+	}()
+	s := NewFileStorage("destDir")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			osMkdirAll = tc.mockOsMkdirAll
+			osCreate = tc.mockOsCreate
+			writeBytesToFile = tc.mockWriteBytesToFile
+			err := s.Put("someurl", http.StatusOK, nil, []byte("body"))
+			if tc.expectedError != nil {
+				require.EqualError(t, err, tc.expectedError.Error())
+			} else {
+				require.Nil(t, err)
+			}
+		})
+	}
+}
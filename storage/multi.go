@@ -0,0 +1,52 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package storage
+
+import "net/http"
+
+// MultiStorage fans a page out to every backend it wraps, so a crawl
+// can be mirrored as a directory tree and archived as WARC in the same
+// run.
+type MultiStorage struct {
+	backends []Storage
+}
+
+// NewMultiStorage creates a MultiStorage that writes every page to each
+// of backends, in order.
+func NewMultiStorage(backends ...Storage) *MultiStorage {
+	return &MultiStorage{backends: backends}
+}
+
+// Exists reports whether any backend already has url.
+func (s *MultiStorage) Exists(url string) bool {
+	for _, backend := range s.backends {
+		if backend.Exists(url) {
+			return true
+		}
+	}
+	return false
+}
+
+// Put saves the page to every backend, stopping at (and returning) the
+// first error.
+func (s *MultiStorage) Put(url string, statusCode int, headers http.Header, body []byte) error {
+	for _, backend := range s.backends {
+		if err := backend.Put(url, statusCode, headers, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every backend, returning the first error encountered,
+// if any, after attempting to close them all.
+func (s *MultiStorage) Close() error {
+	var firstErr error
+	for _, backend := range s.backends {
+		if err := backend.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
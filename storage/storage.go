@@ -0,0 +1,23 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// Package storage provides pluggable backends the crawler can persist
+// fetched pages to.
+package storage
+
+import "net/http"
+
+// Storage persists a fetched page, keyed by its URL, and lets the
+// crawler know whether a URL was already saved so it can be skipped on
+// a re-run.
+type Storage interface {
+	// Exists reports whether url has already been saved.
+	Exists(url string) bool
+	// Put saves the page fetched from url, along with the response's
+	// HTTP status code, headers, and raw body.
+	Put(url string, statusCode int, headers http.Header, body []byte) error
+	// Close releases any resources held by the storage, such as open
+	// files. It is safe to call once Run has finished.
+	Close() error
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"net/http"
+	goUrl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// For ease of unit testing, so we can inject everything we need to.
+var (
+	warcNow = time.Now
+	newUuid = func() string {
+		var b [16]byte
+		rand.Read(b[:])
+		b[6] = (b[6] & 0x0f) | 0x40
+		b[8] = (b[8] & 0x3f) | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	}
+)
+
+// WarcStorage writes fetched pages as WARC 1.1 records: a leading
+// warcinfo record, then one request/response pair per page. Each
+// record is gzip-compressed independently, so the resulting file is a
+// valid multi-member gzip stream, as produced by archival-grade
+// crawlers.
+type WarcStorage struct {
+	userAgent   string
+	basePath    string
+	maxFileSize int64
+
+	mu   sync.Mutex
+	file *os.File
+	seq  int
+	seen map[string]bool
+}
+
+// WarcOption configures optional behavior on a WarcStorage created via
+// NewWarcStorage.
+type WarcOption func(w *WarcStorage)
+
+// WithMaxFileSize rotates to a new, sequentially-numbered WARC file
+// once the current one reaches maxBytes. maxBytes <= 0 (the default)
+// never rotates.
+func WithMaxFileSize(maxBytes int64) WarcOption {
+	return func(w *WarcStorage) {
+		w.maxFileSize = maxBytes
+	}
+}
+
+// NewWarcStorage creates a WarcStorage writing to path, identifying
+// the crawler's requests as userAgent, and immediately writes the
+// leading warcinfo record.
+func NewWarcStorage(path string, userAgent string, opts ...WarcOption) (*WarcStorage, error) {
+	w := &WarcStorage{
+		userAgent: userAgent,
+		basePath:  path,
+		seen:      make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	file, err := osCreate(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating warc file %s", path)
+	}
+	w.file = file
+	if err := w.writeWarcinfo(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Exists reports whether a response record was already written for
+// url during this process' lifetime.
+func (w *WarcStorage) Exists(url string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.seen[url]
+}
+
+// Put appends a request record and a response record for url. If
+// WithMaxFileSize was given and the file has already reached that
+// size, it rotates to a new file first, so a page's request and
+// response records always land in the same file.
+func (w *WarcStorage) Put(url string, statusCode int, headers http.Header, body []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.rotateIfNeeded(); err != nil {
+		return err
+	}
+	if err := w.writeRequestRecord(url); err != nil {
+		return err
+	}
+	if err := w.writeResponseRecord(url, statusCode, headers, body); err != nil {
+		return err
+	}
+	w.seen[url] = true
+	return nil
+}
+
+// Close closes the underlying WARC file.
+func (w *WarcStorage) Close() error {
+	return w.file.Close()
+}
+
+func (w *WarcStorage) writeWarcinfo() error {
+	payload := []byte("software: mirroring-web-crawler\r\n" +
+		"format: WARC File Format 1.1\r\n" +
+		"conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n")
+	return w.writeRecord("warcinfo", "", payload, nil)
+}
+
+func (w *WarcStorage) writeRequestRecord(rawUrl string) error {
+	parsed, err := goUrl.Parse(rawUrl)
+	if err != nil {
+		return errors.Wrapf(err, "parsing url %v", rawUrl)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "GET %s HTTP/1.1\r\n", requestTarget(parsed))
+	fmt.Fprintf(&buf, "Host: %s\r\n", parsed.Host)
+	if w.userAgent != "" {
+		fmt.Fprintf(&buf, "User-Agent: %s\r\n", w.userAgent)
+	}
+	buf.WriteString("\r\n")
+	return w.writeRecord("request", rawUrl, buf.Bytes(), nil)
+}
+
+func (w *WarcStorage) writeResponseRecord(rawUrl string, statusCode int, headers http.Header, body []byte) error {
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", statusCode, http.StatusText(statusCode))
+	for name, values := range headers {
+		for _, value := range values {
+			fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+		}
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return w.writeRecord("response", rawUrl, buf.Bytes(), body)
+}
+
+// writeRecord gzip-compresses a single WARC record and appends it to
+// the file, closing the gzip.Writer so each record is its own gzip
+// member. payload is the full record block (for a response record,
+// the synthesized HTTP message: status line, headers, and body) that
+// Content-Length and WARC-Block-Digest are computed over. payloadBody
+// is the entity body alone; per WARC 1.1 §5.8, WARC-Payload-Digest
+// must hash only the payload, not the surrounding HTTP message, so
+// it's only emitted (and only meaningful) for response records, where
+// it's passed separately from payload.
+func (w *WarcStorage) writeRecord(recordType, targetUri string, payload, payloadBody []byte) error {
+	var record bytes.Buffer
+	record.WriteString("WARC/1.1\r\n")
+	fmt.Fprintf(&record, "WARC-Type: %s\r\n", recordType)
+	if targetUri != "" {
+		fmt.Fprintf(&record, "WARC-Target-URI: %s\r\n", targetUri)
+	}
+	fmt.Fprintf(&record, "WARC-Date: %s\r\n", warcNow().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&record, "WARC-Record-ID: <urn:uuid:%s>\r\n", newUuid())
+	fmt.Fprintf(&record, "Content-Type: %s\r\n", contentTypeFor(recordType))
+	fmt.Fprintf(&record, "Content-Length: %d\r\n", len(payload))
+	fmt.Fprintf(&record, "WARC-Block-Digest: %s\r\n", sha1Digest(payload))
+	if recordType == "response" {
+		fmt.Fprintf(&record, "WARC-Payload-Digest: %s\r\n", sha1Digest(payloadBody))
+	}
+	record.WriteString("\r\n")
+	record.Write(payload)
+	record.WriteString("\r\n\r\n")
+
+	gz := gzip.NewWriter(w.file)
+	if _, err := gz.Write(record.Bytes()); err != nil {
+		return errors.Wrapf(err, "writing %s record for %s", recordType, targetUri)
+	}
+	return gz.Close()
+}
+
+// rotateIfNeeded closes the current file and opens the next
+// sequentially-numbered one if it has reached maxFileSize, writing a
+// fresh warcinfo record to lead it off.
+func (w *WarcStorage) rotateIfNeeded() error {
+	if w.maxFileSize <= 0 {
+		return nil
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "statting warc file")
+	}
+	if info.Size() < w.maxFileSize {
+		return nil
+	}
+	if err := w.file.Close(); err != nil {
+		return errors.Wrap(err, "closing warc file for rotation")
+	}
+	w.seq++
+	path := rotatedPath(w.basePath, w.seq)
+	file, err := osCreate(path)
+	if err != nil {
+		return errors.Wrapf(err, "creating rotated warc file %s", path)
+	}
+	w.file = file
+	return w.writeWarcinfo()
+}
+
+// rotatedPath returns the path used for the seq'th rotation of base,
+// e.g. rotatedPath("crawl.warc.gz", 2) is "crawl-2.warc.gz". seq 0
+// returns base unchanged.
+func rotatedPath(base string, seq int) string {
+	if seq == 0 {
+		return base
+	}
+	ext := ".warc.gz"
+	trimmed := strings.TrimSuffix(base, ext)
+	if trimmed == base {
+		ext = filepath.Ext(base)
+		trimmed = strings.TrimSuffix(base, ext)
+	}
+	return fmt.Sprintf("%s-%d%s", trimmed, seq, ext)
+}
+
+func contentTypeFor(recordType string) string {
+	switch recordType {
+	case "warcinfo":
+		return "application/warc-fields"
+	case "request":
+		return "application/http;msgtype=request"
+	default:
+		return "application/http;msgtype=response"
+	}
+}
+
+func sha1Digest(payload []byte) string {
+	sum := sha1.Sum(payload)
+	return "sha1:" + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(sum[:])
+}
+
+func requestTarget(u *goUrl.URL) string {
+	target := u.Path
+	if target == "" {
+		target = "/"
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+	return target
+}
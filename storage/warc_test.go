@@ -0,0 +1,152 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readWarcRecords gunzips a multi-member gzip stream -- gzip.Reader
+// transparently concatenates every member's decompressed output -- and
+// splits the result back into individual WARC records.
+func readWarcRecords(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.Nil(t, err)
+	decoded, err := io.ReadAll(gz)
+	require.Nil(t, err)
+	records := strings.Split(string(decoded), "WARC/1.1\r\n")
+	return records[1:]
+}
+
+func Test_WarcStorage_Put(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.warc.gz")
+	w, err := NewWarcStorage(path, "somebot")
+	require.Nil(t, err)
+
+	headers := http.Header{"Content-Type": []string{"text/html"}}
+	err = w.Put("https://blog.cleancoder.com/page.html", http.StatusOK, headers, []byte("<html></html>"))
+	require.Nil(t, err)
+	require.True(t, w.Exists("https://blog.cleancoder.com/page.html"))
+	require.False(t, w.Exists("https://blog.cleancoder.com/other.html"))
+
+	require.Nil(t, w.Close())
+
+	records := readWarcRecords(t, path)
+	require.Len(t, records, 3)
+	require.True(t, strings.Contains(records[0], "WARC-Type: warcinfo"))
+	require.True(t, strings.Contains(records[1], "WARC-Type: request"))
+	require.True(t, strings.Contains(records[1], "GET /page.html HTTP/1.1"))
+	require.True(t, strings.Contains(records[2], "WARC-Type: response"))
+	require.True(t, strings.Contains(records[2], "WARC-Payload-Digest: sha1:"))
+	require.True(t, strings.Contains(records[2], "WARC-Block-Digest: sha1:"))
+	require.True(t, strings.Contains(records[2], "Content-Type: text/html"))
+	require.True(t, strings.Contains(records[2], "<html></html>"))
+}
+
+func Test_WarcStorage_Put_StatusLineReflectsStatusCode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.warc.gz")
+	w, err := NewWarcStorage(path, "somebot")
+	require.Nil(t, err)
+
+	err = w.Put("https://blog.cleancoder.com/missing.html", http.StatusNotFound, nil, []byte("not found"))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	records := readWarcRecords(t, path)
+	require.Len(t, records, 3)
+	require.True(t, strings.Contains(records[2], "HTTP/1.1 404 Not Found\r\n"))
+	require.False(t, strings.Contains(records[2], "200 OK"))
+}
+
+func Test_WarcStorage_Put_PayloadDigestIsBodyOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.warc.gz")
+	w, err := NewWarcStorage(path, "somebot")
+	require.Nil(t, err)
+
+	body := []byte("<html><body>hello</body></html>")
+	headers := http.Header{"Content-Type": []string{"text/html"}}
+	err = w.Put("https://blog.cleancoder.com/page.html", http.StatusOK, headers, body)
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	records := readWarcRecords(t, path)
+	require.Len(t, records, 3)
+	response := records[2]
+
+	wantPayloadDigest := sha1Digest(body)
+	require.True(t, strings.Contains(response, "WARC-Payload-Digest: "+wantPayloadDigest))
+
+	// The full HTTP message (status line + headers + body) hashes to a
+	// different digest than the body alone, and is what Block-Digest,
+	// not Payload-Digest, covers.
+	statusAndHeaders := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n"
+	message := append([]byte(statusAndHeaders), body...)
+	wantBlockDigest := sha1Digest(message)
+	require.True(t, strings.Contains(response, "WARC-Block-Digest: "+wantBlockDigest))
+	require.NotEqual(t, wantPayloadDigest, wantBlockDigest)
+}
+
+func Test_WarcStorage_Rotation(t *testing.T) {
+	dir := t.TempDir()
+
+	// Fix the record IDs and dates, which otherwise vary the compressed
+	// size of each record, so both files below compress identically.
+	originalNow, originalUuid := warcNow, newUuid
+	defer func() { warcNow, newUuid = originalNow, originalUuid }()
+	fixedNow := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	warcNow = func() time.Time { return fixedNow }
+	newUuid = func() string { return "00000000-0000-4000-8000-000000000000" }
+
+	// Write a single page with no rotation to learn exactly how many
+	// bytes a file containing the warcinfo record plus one page takes,
+	// then use that as the rotation threshold so the second page is
+	// guaranteed to land in a new file.
+	probePath := filepath.Join(dir, "probe.warc.gz")
+	probe, err := NewWarcStorage(probePath, "somebot")
+	require.Nil(t, err)
+	require.Nil(t, probe.Put("https://blog.cleancoder.com/page1.html", http.StatusOK, nil, []byte("<html></html>")))
+	require.Nil(t, probe.Close())
+	info, err := os.Stat(probePath)
+	require.Nil(t, err)
+
+	path := filepath.Join(dir, "out.warc.gz")
+	w, err := NewWarcStorage(path, "somebot", WithMaxFileSize(info.Size()))
+	require.Nil(t, err)
+
+	require.Nil(t, w.Put("https://blog.cleancoder.com/page1.html", http.StatusOK, nil, []byte("<html></html>")))
+	require.Nil(t, w.Put("https://blog.cleancoder.com/page2.html", http.StatusOK, nil, []byte("<html></html>")))
+	require.Nil(t, w.Close())
+
+	firstFile := path
+	secondFile := strings.TrimSuffix(path, ".warc.gz") + "-1.warc.gz"
+	require.FileExists(t, firstFile)
+	require.FileExists(t, secondFile)
+
+	firstRecords := readWarcRecords(t, firstFile)
+	require.True(t, strings.Contains(firstRecords[0], "WARC-Type: warcinfo"))
+	require.True(t, strings.Contains(firstRecords[1], "page1.html"))
+
+	secondRecords := readWarcRecords(t, secondFile)
+	require.True(t, strings.Contains(secondRecords[0], "WARC-Type: warcinfo"))
+	require.True(t, strings.Contains(secondRecords[1], "page2.html"))
+}
+
+func Test_rotatedPath(t *testing.T) {
+	require.Equal(t, "crawl.warc.gz", rotatedPath("crawl.warc.gz", 0))
+	require.Equal(t, "crawl-2.warc.gz", rotatedPath("crawl.warc.gz", 2))
+	require.Equal(t, "crawl-3.warc", rotatedPath("crawl.warc", 3))
+}